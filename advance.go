@@ -0,0 +1,82 @@
+package iic
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/beevik/etree"
+)
+
+// AdvanceInvType is InvType of an invoice for an advance payment
+const AdvanceInvType = "ADVANCE"
+
+// AdvanceRef references an invoice of an advance payment settled by the invoice, IICRef element of IICRefs
+type AdvanceRef struct {
+	IIC           string
+	IssueDateTime string
+	Amount        string
+}
+
+// IsAdvanceInvoice reports whether the invoice is for an advance payment, or settles advance payments, i.e.
+// references their invoices with IICRefs
+func IsAdvanceInvoice(invoice *etree.Element) bool {
+	return invoice.SelectAttrValue("InvType", "") == AdvanceInvType || invoice.SelectElement("IICRefs") != nil
+}
+
+// AdvanceRefs returns advance payments referenced by the invoice and checks that each has IIC of the advance
+// invoice, its IssueDateTime and the settled Amount. The IIC of an invoice settling advances is generated from its
+// TotPrice as stated, just like of any invoice: settled amounts are neither added to it nor subtracted
+func AdvanceRefs(invoice *etree.Element) ([]AdvanceRef, error) {
+	refs := []AdvanceRef{}
+	container := invoice.SelectElement("IICRefs")
+	if container == nil {
+		return refs, nil
+	}
+	elements := container.SelectElements("IICRef")
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("IICRefs of the invoice settling advance payments has no IICRef")
+	}
+	for i, element := range elements {
+		ref := AdvanceRef{
+			IIC:           element.SelectAttrValue("IIC", ""),
+			IssueDateTime: element.SelectAttrValue("IssueDateTime", ""),
+			Amount:        element.SelectAttrValue("Amount", ""),
+		}
+		for _, field := range [][2]string{{"IIC", ref.IIC}, {"IssueDateTime", ref.IssueDateTime}, {"Amount", ref.Amount}} {
+			if field[1] == "" {
+				return nil, fmt.Errorf("IICRef %d: %s of the advance invoice is missing", i+1, field[0])
+			}
+		}
+		if !iicRegexp.MatchString(ref.IIC) {
+			return nil, fmt.Errorf("IICRef %d: IIC %q is not an IIC of the advance invoice", i+1, ref.IIC)
+		}
+		if _, err := parseDateTime(ref.IssueDateTime); err != nil {
+			return nil, fmt.Errorf("IICRef %d: IssueDateTime %q of the advance invoice is not in RFC 3339 format", i+1, ref.IssueDateTime)
+		}
+		if _, ok := new(big.Rat).SetString(ref.Amount); !ok {
+			return nil, fmt.Errorf("IICRef %d: Amount %q settled by the advance is not a number", i+1, ref.Amount)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// RuleAdvanceReference requires advance payments referenced by the invoice to be complete, see AdvanceRefs
+var RuleAdvanceReference = Rule{
+	Name: "advance-reference",
+	Check: func(doc *etree.Document, invoice *etree.Element, fields [7]string) error {
+		_, err := AdvanceRefs(invoice)
+		return err
+	},
+}
+
+// checkAdvance fails if the invoice references advance payments with missing or malformed fields
+func checkAdvance(invoice *etree.Element) error {
+	if !IsAdvanceInvoice(invoice) {
+		return nil
+	}
+	if _, err := AdvanceRefs(invoice); err != nil {
+		return documentError(err)
+	}
+	return nil
+}
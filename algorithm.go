@@ -0,0 +1,114 @@
+package iic
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+)
+
+// SignatureScheme is the signature scheme the spec requires for IICSignature: RSASSA-PKCS1-v1_5 with sha256.
+// Signer.SignPKCS1v15 must create signatures of this scheme for RSA keys, never RSASSA-PSS, see CheckPKCS1v15.
+// SafeNet guarantees it by signing DigestInfo of the digest with CKM_RSA_PKCS mechanism
+const SignatureScheme = "RSASSA-PKCS1-v1_5"
+
+// Algorithm identifies the signature algorithm of IICSignature. IIC is always md5 hash of the signature
+type Algorithm int
+
+const (
+	// AlgorithmRSA is RSASSA-PKCS1-v1_5 with sha256, required by the current spec
+	AlgorithmRSA Algorithm = iota
+	// AlgorithmECDSA is ASN.1 encoded ECDSA with sha256, for when the authority permits ECDSA certificates
+	AlgorithmECDSA
+)
+
+// String returns name of the algorithm
+func (a Algorithm) String() string {
+	switch a {
+	case AlgorithmRSA:
+		return "RSA"
+	case AlgorithmECDSA:
+		return "ECDSA"
+	default:
+		return "unknown"
+	}
+}
+
+// KeySigner adapts crypto.Signer, e.g. a software or cloud KMS key, to Signer creating signatures of given
+// Algorithm. For AlgorithmECDSA its SignPKCS1v15 returns ECDSA signature, the name is kept to satisfy Signer
+type KeySigner struct {
+	key       crypto.Signer
+	algorithm Algorithm
+}
+
+// NewKeySigner creates KeySigner after checking that the key type is compatible with algorithm
+func NewKeySigner(key crypto.Signer, algorithm Algorithm) (*KeySigner, error) {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		if algorithm != AlgorithmRSA {
+			return nil, fmt.Errorf("RSA key can't be used for %s", algorithm)
+		}
+		if pub.Size() < minSignatureLength {
+			return nil, fmt.Errorf("RSA key of %d bits is too weak", pub.N.BitLen())
+		}
+	case *ecdsa.PublicKey:
+		if algorithm != AlgorithmECDSA {
+			return nil, fmt.Errorf("ECDSA key can't be used for %s", algorithm)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+	signer := &KeySigner{key: key, algorithm: algorithm}
+	if algorithm == AlgorithmRSA {
+		if err := CheckPKCS1v15(signer); err != nil {
+			return nil, err
+		}
+	}
+	return signer, nil
+}
+
+// SignPKCS1v15 signs sha256 digest with the key using the algorithm of the signer
+func (s *KeySigner) SignPKCS1v15(data []byte) ([]byte, error) {
+	return s.key.Sign(rand.Reader, data, crypto.SHA256)
+}
+
+// Algorithm returns the algorithm of signatures created by the signer
+func (s *KeySigner) Algorithm() Algorithm {
+	return s.algorithm
+}
+
+// CheckPKCS1v15 checks that signer creates SignatureScheme signatures: signing is deterministic, unlike
+// RSASSA-PSS, and the signature verifies as RSASSA-PKCS1-v1_5 with the public key, taken from the key of
+// KeySigner or the certificate of CertificateSource. Signers providing neither are checked for determinism only
+func CheckPKCS1v15(signer Signer) error {
+	nonce := make([]byte, crypto.SHA256.Size())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	first, err := signer.SignPKCS1v15(nonce)
+	if err != nil {
+		return signerError(err)
+	}
+	second, err := signer.SignPKCS1v15(nonce)
+	if err != nil {
+		return signerError(err)
+	}
+	if !bytes.Equal(first, second) {
+		return fmt.Errorf("signer %T isn't deterministic, so it doesn't create %s signatures", signer, SignatureScheme)
+	}
+
+	var pub crypto.PublicKey
+	if s, ok := signer.(*KeySigner); ok {
+		pub = s.key.Public()
+	} else if cert, err := certificateOf(signer); err == nil {
+		pub = cert.PublicKey
+	}
+	if rsaPub, ok := pub.(*rsa.PublicKey); ok {
+		if err := rsa.VerifyPKCS1v15(rsaPub, crypto.SHA256, nonce, first); err != nil {
+			return fmt.Errorf("signer %T doesn't create %s signatures: %v", signer, SignatureScheme, err)
+		}
+	}
+	return nil
+}
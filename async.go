@@ -0,0 +1,42 @@
+package iic
+
+import "context"
+
+// AsyncResult represents outcome of signing single InvoiceFields received by SignAsync
+type AsyncResult struct {
+	Fields       InvoiceFields
+	IIC          string
+	IICSignature string
+	Err          error
+}
+
+// SignAsync generates IIC for every InvoiceFields received from in and emits results in the same order.
+// Signing is serialized, so the signer session is never used concurrently. The returned channel is
+// closed after in is closed or ctx is done; fields received after cancellation aren't signed
+func SignAsync(ctx context.Context, signer Signer, in <-chan InvoiceFields) <-chan AsyncResult {
+	out := make(chan AsyncResult)
+	go func() {
+		defer close(out)
+		for {
+			var fields InvoiceFields
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case fields, ok = <-in:
+				if !ok {
+					return
+				}
+			}
+
+			result := AsyncResult{Fields: fields}
+			result.IIC, result.IICSignature, result.Err = generateIIC(signer, fields.Array())
+			select {
+			case <-ctx.Done():
+				return
+			case out <- result:
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,102 @@
+package iic
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// ExportAuditText returns a stable plain text record of values every Invoice of doc fed into its IIC:
+// one line per invoice with labeled fields followed by the plain IIC string
+func ExportAuditText(doc *etree.Document) (string, error) {
+	invoices := doc.FindElements("//Invoice")
+	if len(invoices) == 0 {
+		return "", documentError(fmt.Errorf("can't find element %s", "//Invoice"))
+	}
+
+	builder := strings.Builder{}
+	for i, invoice := range invoices {
+		params, err := parseInvoice(doc, invoice, ParseOptions{})
+		if err != nil {
+			return "", documentError(fmt.Errorf("invoice %d: %v", i+1, err))
+		}
+		fmt.Fprintf(&builder, "Invoice=%d", i+1)
+		for j, name := range FieldNames {
+			fmt.Fprintf(&builder, " %s=%q", name, params[j])
+		}
+		fmt.Fprintf(&builder, " PlainIIC=%q\n", PlainIIC(params))
+	}
+	return builder.String(), nil
+}
+
+// AuditReport represents outcome of AuditSignedDocument, serializable to JSON for audit records
+type AuditReport struct {
+	Certificate CertificateInfo `json:"Certificate"`
+	Invoices    []InvoiceAudit  `json:"Invoices"`
+}
+
+// InvoiceAudit represents checks of a single Invoice of the audited document. Problems explain failed checks
+type InvoiceAudit struct {
+	Index            int       `json:"Index"`
+	Fields           [7]string `json:"Fields"`
+	IIC              string    `json:"IIC"`
+	IICSignature     string    `json:"IICSignature"`
+	SignatureValid   bool      `json:"SignatureValid"`
+	IICMatches       bool      `json:"IICMatches"`
+	CertificateValid bool      `json:"CertificateValid"`
+	Problems         []string  `json:"Problems,omitempty"`
+}
+
+// Valid reports whether every check of the invoice passed
+func (a InvoiceAudit) Valid() bool {
+	return a.SignatureValid && a.IICMatches && a.CertificateValid
+}
+
+// AuditSignedDocument checks every Invoice of signed doc against PEM encoded certificate: whether IICSignature
+// verifies, whether IIC is md5 hash of IICSignature and whether the certificate was valid at IssueDateTime.
+// Unlike VerifyIIC, every check is performed and reported regardless of failures of the others. Like for
+// VerifyIIC, hex digits of IIC and IICSignature may be of either case
+func AuditSignedDocument(certPEM []byte, doc *etree.Document) (AuditReport, error) {
+	cert, err := parseCertificatePEM(certPEM)
+	if err != nil {
+		return AuditReport{}, err
+	}
+	signed, err := SignedInvoices(doc)
+	if err != nil {
+		return AuditReport{}, err
+	}
+
+	report := AuditReport{Certificate: InfoOfCertificate(cert), Invoices: make([]InvoiceAudit, len(signed))}
+	for i, invoice := range signed {
+		audit := InvoiceAudit{Index: i, Fields: invoice.Fields, IIC: invoice.IIC, IICSignature: invoice.IICSignature}
+
+		signature, err := hex.DecodeString(invoice.IICSignature)
+		if err != nil {
+			audit.Problems = append(audit.Problems, fmt.Sprintf("IICSignature is not hex: %v", err))
+		} else {
+			if err := verifySignature(cert.PublicKey, DigestForIIC(invoice.Fields), signature); err != nil {
+				audit.Problems = append(audit.Problems, fmt.Sprintf("signature doesn't verify: %v", err))
+			} else {
+				audit.SignatureValid = true
+			}
+			if !strings.EqualFold(fmt.Sprintf("%x", md5.Sum(signature)), invoice.IIC) {
+				audit.Problems = append(audit.Problems, "IIC is not md5 hash of IICSignature")
+			} else {
+				audit.IICMatches = true
+			}
+		}
+
+		if issued, err := parseDateTime(invoice.Fields[1]); err != nil {
+			audit.Problems = append(audit.Problems, fmt.Sprintf("IssueDateTime %s is invalid: %v", invoice.Fields[1], err))
+		} else if issued.Before(cert.NotBefore) || issued.After(cert.NotAfter) {
+			audit.Problems = append(audit.Problems, fmt.Sprintf("issued at %s, certificate is valid from %s to %s", invoice.Fields[1], cert.NotBefore, cert.NotAfter))
+		} else {
+			audit.CertificateValid = true
+		}
+		report.Invoices[i] = audit
+	}
+	return report, nil
+}
@@ -0,0 +1,36 @@
+package iic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAuditSignedDocument(t *testing.T) {
+	signer, certPEM := newTestSigner(t)
+	IIC, IICSignature, err := generateIIC(signer, testInvoiceFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		IIC          string
+		IICSignature string
+		valid        bool
+	}{
+		{"lowercase", IIC, IICSignature, true},
+		{"uppercase", strings.ToUpper(IIC), strings.ToUpper(IICSignature), true},
+		{"other IIC", strings.Repeat("0", len(IIC)), IICSignature, false},
+	}
+	for _, test := range tests {
+		doc := readTestDocument(t, testInvoice)
+		SetIIC(doc.FindElement("//Invoice"), test.IIC, test.IICSignature)
+		report, err := AuditSignedDocument(certPEM, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		audit := report.Invoices[0]
+		if audit.Valid() != test.valid || audit.IICMatches != test.valid || !audit.SignatureValid || !audit.CertificateValid {
+			t.Errorf("%s: audit is %+v, want valid %v", test.name, audit, test.valid)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package authority cross-checks IICs with the tax authority's public invoice verifier.
+// It's kept apart from package iic, so the core doesn't depend on HTTP
+package authority
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultEndpoint is the address of the verifier API behind iic.VerificationBaseURL
+const DefaultEndpoint = "https://mapr.tax.gov.me/ic/api/verifyInvoice"
+
+var (
+	// ErrNotFound is returned when the verifier doesn't know the invoice
+	ErrNotFound = errors.New("invoice not found by the verifier")
+	// ErrRateLimited is returned when the verifier rejects the request due to rate limits, see RateLimitError
+	ErrRateLimited = errors.New("rate limited by the verifier")
+)
+
+// RateLimitError is returned when the verifier responds with 429. RetryAfter is zero if it isn't announced
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%v, retry after %s", ErrRateLimited, e.RetryAfter)
+	}
+	return ErrRateLimited.Error()
+}
+
+// Is classifies RateLimitError as ErrRateLimited
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// Client queries the verifier. Zero value uses DefaultEndpoint and http.DefaultClient
+type Client struct {
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+// verifiedInvoice is the part of the verifier response compared with the invoice
+type verifiedInvoice struct {
+	IIC                string      `json:"iic"`
+	TIN                string      `json:"tin"`
+	DateTimeCreated    string      `json:"dateTimeCreated"`
+	InvoiceOrderNumber json.Number `json:"invoiceOrderNumber"`
+	BusinessUnitCode   string      `json:"businessUnitCode"`
+	TCRCode            string      `json:"tcrCode"`
+	SoftwareCode       string      `json:"softwareCode"`
+	TotalPrice         json.Number `json:"totalPrice"`
+}
+
+// CrossCheckWithAuthority is the same as Client.CrossCheck of zero Client
+func CrossCheckWithAuthority(ctx context.Context, params [7]string, iic string) (bool, error) {
+	return (&Client{}).CrossCheck(ctx, params, iic)
+}
+
+// CrossCheck queries the verifier for the invoice with given IIC and reports whether every value it returns
+// matches params. Orders of parameters are the same as for iic.GenerateIIC. Returns ErrNotFound if the
+// verifier doesn't know the invoice and *RateLimitError if it's rate limited
+func (c *Client) CrossCheck(ctx context.Context, params [7]string, iic string) (bool, error) {
+	invoice, err := c.verify(ctx, params, iic)
+	if err != nil {
+		return false, err
+	}
+	return matches(invoice, params, iic), nil
+}
+
+// verify posts the request to the verifier and decodes its response
+func (c *Client) verify(ctx context.Context, params [7]string, iic string) (*verifiedInvoice, error) {
+	endpoint := c.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = DefaultEndpoint
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	form := url.Values{}
+	form.Set("iic", iic)
+	form.Set("tin", params[0])
+	form.Set("dateTimeCreated", params[1])
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("verifier request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusTooManyRequests:
+		return nil, &RateLimitError{RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	default:
+		return nil, fmt.Errorf("verifier responded with %s", resp.Status)
+	}
+
+	invoice := &verifiedInvoice{}
+	if err := json.NewDecoder(resp.Body).Decode(invoice); err != nil {
+		return nil, fmt.Errorf("malformed verifier response: %v", err)
+	}
+	if len(invoice.IIC) == 0 {
+		return nil, ErrNotFound
+	}
+	return invoice, nil
+}
+
+// matches compares values returned by the verifier with params. Numbers are compared by value,
+// dates by instant, since the verifier may format them differently
+func matches(invoice *verifiedInvoice, params [7]string, iic string) bool {
+	return strings.EqualFold(invoice.IIC, iic) &&
+		invoice.TIN == params[0] &&
+		sameTime(invoice.DateTimeCreated, params[1]) &&
+		sameNumber(string(invoice.InvoiceOrderNumber), params[2]) &&
+		invoice.BusinessUnitCode == params[3] &&
+		invoice.TCRCode == params[4] &&
+		invoice.SoftwareCode == params[5] &&
+		sameNumber(string(invoice.TotalPrice), params[6])
+}
+
+// sameTime checks that a and b are RFC 3339 representations of the same instant
+func sameTime(a, b string) bool {
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	return errA == nil && errB == nil && ta.Equal(tb)
+}
+
+// sameNumber checks that a and b represent the same number
+func sameNumber(a, b string) bool {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	return errA == nil && errB == nil && fa == fb
+}
+
+// retryAfter parses Retry-After header given in seconds or as HTTP date
+func retryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
@@ -0,0 +1,207 @@
+package iic
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// BatchPolicy defines how WriteIICBatch reacts to a failed item
+type BatchPolicy int
+
+const (
+	// ContinueOnError processes every item regardless of errors
+	ContinueOnError BatchPolicy = iota
+	// AbortOnSignerError continues on document errors, but stops the batch on the first signer error,
+	// since every subsequent item would fail as well
+	AbortOnSignerError
+)
+
+// BatchItem represents single input and output file pair of a batch
+type BatchItem struct {
+	InFile  string
+	OutFile string
+}
+
+// BatchParams represents collection of parameters needed for WriteIICBatch function.
+// Params are applied to every item, their InFile and OutFile are ignored.
+// Workers above one sign items concurrently, each in its own session of a single SafeNet context initialized
+// from SafenetConfig. They're capped to MaxSessions, or to the session limit reported by the token if it's zero,
+// see TokenMaxSessions. The batch fails if any of the sessions can't be opened.
+// Workers are ignored when Signer or Registry is set, as a single session can't be used concurrently.
+// Timing captures Timings of every item, see SummarizeBatch.
+// Retries is how many times an item failed by the signer is retried, RetryDelay is the pause before a retry.
+// RetryBudget limits retries across the whole batch, zero means no limit; the batch aborts with
+// ErrRetryBudgetExhausted once it's used up
+type BatchParams struct {
+	Params
+	Items       []BatchItem
+	Policy      BatchPolicy
+	Workers     int
+	MaxSessions int
+	Timing      bool
+	Retries     int
+	RetryDelay  time.Duration
+	RetryBudget int
+}
+
+// BatchResult represents outcome of a single batch item. Timings are captured only if BatchParams.Timing is set.
+// Retries is how many times the item was retried
+type BatchResult struct {
+	BatchItem
+	Timings
+	IIC          string
+	IICSignature string
+	Warnings     []Warning
+	Retries      int
+	Err          error
+}
+
+// BatchSummary counts batch items by outcome and totals their Timings and retries, i.e. consumed retry budget
+type BatchSummary struct {
+	Timings
+	Succeeded int
+	Failed    int
+	Retries   int
+}
+
+// SummarizeBatch counts batch results by outcome and totals their Timings
+func SummarizeBatch(results []BatchResult) BatchSummary {
+	summary := BatchSummary{}
+	for _, result := range results {
+		if result.Err != nil {
+			summary.Failed++
+		} else {
+			summary.Succeeded++
+		}
+		summary.add(result.Timings)
+		summary.Retries += result.Retries
+	}
+	return summary
+}
+
+// processItem generates IIC for single batch item using given signer, retrying signer failures up to
+// params.Retries times while budget allows. Fails with ErrRetryBudgetExhausted when the budget is used up
+func processItem(signer Signer, params *BatchParams, item BatchItem, budget *retryBudget) (BatchResult, error) {
+	result := attemptItem(signer, params, item)
+	for result.Retries < params.Retries && retryable(result.Err) {
+		if !budget.take() {
+			return result, fmt.Errorf("%w after %d retries, the signer fails systemically: %v", ErrRetryBudgetExhausted, params.RetryBudget, result.Err)
+		}
+		time.Sleep(params.RetryDelay)
+		retries := result.Retries + 1
+		result = attemptItem(signer, params, item)
+		result.Retries = retries
+	}
+	return result, nil
+}
+
+// attemptItem generates IIC for single batch item using given signer
+func attemptItem(signer Signer, params *BatchParams, item BatchItem) BatchResult {
+	itemParams := params.Params
+	itemParams.InFile = item.InFile
+	itemParams.OutFile = item.OutFile
+	itemParams.warnings = nil
+	itemParams.timings = nil
+	itemParams.reserved = nil
+	if params.Timing {
+		itemParams.timings = &Timings{}
+	}
+
+	IIC, IICSignature, err := writeIIC(signer, &itemParams)
+	result := BatchResult{
+		BatchItem:    item,
+		IIC:          IIC,
+		IICSignature: IICSignature,
+		Warnings:     itemParams.takeWarnings(),
+		Err:          err,
+	}
+	if itemParams.timings != nil {
+		result.Timings = *itemParams.timings
+	}
+	return result
+}
+
+// WriteIICBatch generates IIC for every item of the batch using single signer session.
+// Returns results of processed items and non-nil error if the batch was aborted
+func WriteIICBatch(params *BatchParams) ([]BatchResult, error) {
+	if params == nil {
+		return nil, fmt.Errorf("params: nil")
+	}
+	if err := validateParams(&params.Params); err != nil {
+		return nil, err
+	}
+
+	if params.Workers > 1 && params.Signer == nil && params.Registry == nil {
+		return writeIICBatchConcurrent(params)
+	}
+
+	var results []BatchResult
+	err := withSigner(&params.Params, func(signer Signer) error {
+		var err error
+		results, err = writeIICBatch(signer, params)
+		return err
+	})
+	return results, err
+}
+
+// writeIICBatch processes items one by one using given signer, respecting the policy
+func writeIICBatch(signer Signer, params *BatchParams) ([]BatchResult, error) {
+	results := make([]BatchResult, 0, len(params.Items))
+	budget := newRetryBudget(params.RetryBudget)
+	for _, item := range params.Items {
+		result, err := processItem(signer, params, item, budget)
+		results = append(results, result)
+		if err != nil {
+			return results, fmt.Errorf("batch aborted on %s: %w", item.InFile, err)
+		}
+		if err := result.Err; err != nil && params.Policy == AbortOnSignerError && errors.Is(err, ErrSigner) {
+			return results, fmt.Errorf("batch aborted on %s: %w", item.InFile, err)
+		}
+	}
+	return results, nil
+}
+
+// BatchValidationResult represents readiness of a single file for signing, found by BatchDryRun.
+// The file is ready when Err is nil
+type BatchValidationResult struct {
+	File     string
+	Fields   [7]string
+	PlainIIC string
+	Err      error
+}
+
+// BatchDryRun parses and validates every file with ValidateDocument without signing, so all problems
+// can be fixed before the signing pass. No signer is needed
+func BatchDryRun(files []string) []BatchValidationResult {
+	return BatchDryRunWithOptions(files, ParseOptions{}, ValidateOptions{})
+}
+
+// BatchDryRunWithOptions is the same as BatchDryRun, but parses and validates documents with given options
+func BatchDryRunWithOptions(files []string, parseOpts ParseOptions, opts ValidateOptions) []BatchValidationResult {
+	results := make([]BatchValidationResult, len(files))
+	for i, file := range files {
+		results[i] = dryRun(file, parseOpts, opts)
+	}
+	return results
+}
+
+// dryRun checks readiness of single file for signing
+func dryRun(file string, parseOpts ParseOptions, opts ValidateOptions) BatchValidationResult {
+	result := BatchValidationResult{File: file}
+	doc, _, err := readDocument(file)
+	if err != nil {
+		result.Err = documentError(err)
+		return result
+	}
+	if err := ValidateDocument(doc, parseOpts, opts); err != nil {
+		result.Err = err
+		return result
+	}
+	if result.Fields, err = parse(doc, parseOpts); err != nil {
+		result.Err = documentError(err)
+		return result
+	}
+	result.PlainIIC = PlainIIC(result.Fields)
+	return result
+}
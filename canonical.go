@@ -0,0 +1,20 @@
+package iic
+
+import (
+	"github.com/beevik/etree"
+	"github.com/noshto/dsig/pkg/signedxml"
+)
+
+// Canonicalize returns exclusive canonical XML (http://www.w3.org/2001/10/xml-exc-c14n#) of given doc,
+// which is exactly what XML-DSIG signs
+func Canonicalize(doc *etree.Document) ([]byte, error) {
+	str, err := doc.WriteToString()
+	if err != nil {
+		return nil, err
+	}
+	canonical, err := signedxml.ExclusiveCanonicalization{}.Process(str, "")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(canonical), nil
+}
@@ -0,0 +1,61 @@
+package iic
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// oidOrganizationIdentifier is the subject attribute holding e.g. VATME-12345678
+var oidOrganizationIdentifier = asn1.ObjectIdentifier{2, 5, 4, 97}
+
+// CertificateInfo represents human readable details of a signing certificate.
+// Serial is hex encoded, Thumbprint is the one of CertificateThumbprint
+type CertificateInfo struct {
+	Subject    string
+	TIN        string
+	Serial     string
+	Issuer     string
+	NotBefore  time.Time
+	NotAfter   time.Time
+	Thumbprint string
+}
+
+// InfoOfCertificate collects details of given certificate
+func InfoOfCertificate(cert *x509.Certificate) CertificateInfo {
+	tin, _ := TINFromCertificate(cert)
+	return CertificateInfo{
+		Subject:    cert.Subject.String(),
+		TIN:        tin,
+		Serial:     fmt.Sprintf("%x", cert.SerialNumber),
+		Issuer:     cert.Issuer.String(),
+		NotBefore:  cert.NotBefore,
+		NotAfter:   cert.NotAfter,
+		Thumbprint: CertificateThumbprint(cert),
+	}
+}
+
+// TINFromCertificate extracts TIN of the certificate owner. It's looked up in the subject
+// serial number first and then in the organization identifier, ignoring non-digit prefixes
+func TINFromCertificate(cert *x509.Certificate) (string, error) {
+	candidates := []string{cert.Subject.SerialNumber}
+	for _, name := range cert.Subject.Names {
+		if name.Type.Equal(oidOrganizationIdentifier) {
+			if value, ok := name.Value.(string); ok {
+				candidates = append(candidates, value)
+			}
+		}
+	}
+	for _, candidate := range candidates {
+		tin := strings.TrimLeftFunc(candidate, func(r rune) bool {
+			return !unicode.IsDigit(r)
+		})
+		if len(tin) > 0 {
+			return tin, nil
+		}
+	}
+	return "", fmt.Errorf("can't find TIN in certificate %s", cert.Subject)
+}
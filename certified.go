@@ -0,0 +1,65 @@
+package iic
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"io"
+)
+
+// CertifiedSigner combines a key, e.g. on the token, with an externally distributed certificate.
+// The certificate is used for TIN and expiry checks, and by crypto.Signer consumers such as XML-DSIG
+type CertifiedSigner struct {
+	key  Signer
+	cert *x509.Certificate
+}
+
+// NewCertifiedSigner creates CertifiedSigner after checking that the certificate allows signing, see CheckKeyUsage,
+// and that its public key matches the key, by signing a random nonce and verifying the signature
+func NewCertifiedSigner(key Signer, cert *x509.Certificate) (*CertifiedSigner, error) {
+	if err := CheckKeyUsage(cert); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, crypto.SHA256.Size())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	signature, err := key.SignPKCS1v15(nonce)
+	if err != nil {
+		return nil, signerError(err)
+	}
+	if err := verifySignature(cert.PublicKey, nonce, signature); err != nil {
+		return nil, fmt.Errorf("certificate %s doesn't match the key: %v", cert.Subject, err)
+	}
+	return &CertifiedSigner{key: key, cert: cert}, nil
+}
+
+// NewCertifiedSignerPEM is the same as NewCertifiedSigner, but takes PEM encoded certificate
+func NewCertifiedSignerPEM(key Signer, certPEM []byte) (*CertifiedSigner, error) {
+	cert, err := parseCertificatePEM(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	return NewCertifiedSigner(key, cert)
+}
+
+// SignPKCS1v15 signs data with the key
+func (s *CertifiedSigner) SignPKCS1v15(data []byte) ([]byte, error) {
+	return s.key.SignPKCS1v15(data)
+}
+
+// GetCertificate returns the externally provided certificate
+func (s *CertifiedSigner) GetCertificate() (x509.Certificate, error) {
+	return *s.cert, nil
+}
+
+// Public implements crypto.Signer interface. Returns public key of the certificate
+func (s *CertifiedSigner) Public() crypto.PublicKey {
+	return s.cert.PublicKey
+}
+
+// Sign implements crypto.Signer interface. Signs sha256 digest with RSASSA-PKCS1-v1_5
+func (s *CertifiedSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.key.SignPKCS1v15(digest)
+}
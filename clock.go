@@ -0,0 +1,32 @@
+package iic
+
+import "time"
+
+// Clock provides current time to time-based checks, e.g. certificate expiry, so tests can fix it
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is Clock returning current system time, it's used by default
+type SystemClock struct{}
+
+// Now returns current system time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock is Clock always returning the same time
+type FixedClock time.Time
+
+// Now returns the fixed time
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// clockOrSystem returns clock, or SystemClock if it's nil
+func clockOrSystem(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock{}
+	}
+	return clock
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/noshto/iic"
+)
+
+// certinfo prints details of the certificate on the configured token
+func certinfo(args []string) error {
+	flags := flag.NewFlagSet("certinfo", flag.ExitOnError)
+	signerFlags := addSignerFlags(flags)
+	flags.Parse(args)
+
+	signer, err := signerFlags.initialize()
+	if err != nil {
+		return err
+	}
+	defer signer.Finalize()
+
+	cert, err := signer.GetCertificate()
+	if err != nil {
+		return err
+	}
+	info := iic.InfoOfCertificate(&cert)
+	fmt.Printf("Subject: %s\n", info.Subject)
+	fmt.Printf("TIN: %s\n", info.TIN)
+	fmt.Printf("Serial: %s\n", info.Serial)
+	fmt.Printf("Issuer: %s\n", info.Issuer)
+	fmt.Printf("Valid from: %s\n", info.NotBefore)
+	fmt.Printf("Valid until: %s\n", info.NotAfter)
+	fmt.Printf("Thumbprint (SHA-256): %s\n", info.Thumbprint)
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/noshto/iic"
+)
+
+// compat regenerates IIC of signed documents matching the glob with the configured token and reports
+// invoices whose IIC differs from the one in the document, e.g. for migration from another implementation
+func compat(args []string) error {
+	flags := flag.NewFlagSet("compat", flag.ExitOnError)
+	glob := flags.String("glob", "*.xml", "pattern of signed files to compare")
+	signerFlags := addSignerFlags(flags)
+	flags.Parse(args)
+
+	files, err := filepath.Glob(*glob)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no files match %s", *glob)
+	}
+
+	signer, err := signerFlags.initialize()
+	if err != nil {
+		return err
+	}
+	defer signer.Finalize()
+
+	results := iic.CompareCorpus(signer, files, iic.ParseOptions{})
+	mismatched := 0
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			fmt.Printf("%s: error: %v\n", result.File, result.Err)
+		case !result.Match():
+			fmt.Printf("%s: invoice %d: IIC %s in file, %s generated from %q\n", result.File, result.Index+1, result.OldIIC, result.NewIIC, result.PlainIIC)
+		default:
+			continue
+		}
+		mismatched++
+	}
+	fmt.Printf("%d invoices compared, %d mismatched\n", len(results), mismatched)
+
+	if mismatched > 0 {
+		return fmt.Errorf("IIC of %d invoices doesn't match", mismatched)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+
+	"github.com/noshto/dsig/pkg/safenet"
+	"github.com/noshto/iic"
+)
+
+// signerFlags represents flags needed for initializing SafeNet signer
+type signerFlags struct {
+	configPath *string
+	pinPrompt  *bool
+}
+
+// addSignerFlags registers signer flags in given flag set
+func addSignerFlags(flags *flag.FlagSet) *signerFlags {
+	return &signerFlags{
+		configPath: flags.String("config", "config.json", "path to SafeNet configuration"),
+		pinPrompt:  flags.Bool("pin-prompt", false, "prompt PIN from the terminal instead of reading it from configuration"),
+	}
+}
+
+// initialize loads configuration and initializes SafeNet signer. Prompted PIN is cleared from configuration afterwards
+func (f *signerFlags) initialize() (*safenet.SafeNet, error) {
+	config, err := loadConfig(*f.configPath)
+	if err != nil {
+		return nil, err
+	}
+	if *f.pinPrompt {
+		if err := iic.PromptPIN(config); err != nil {
+			return nil, err
+		}
+		defer iic.ClearPIN(config)
+	}
+
+	signer := &safenet.SafeNet{}
+	if err := signer.Initialize(config); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// loadConfig reads SafeNet configuration from JSON file
+func loadConfig(path string) (*safenet.Config, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &safenet.Config{}
+	if err := json.Unmarshal(buf, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/noshto/iic"
+)
+
+// diff prints IIC fields which differ between two invoices
+func diff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		return fmt.Errorf("usage: iic diff a.xml b.xml")
+	}
+
+	a, err := iic.ParseFile(flags.Arg(0), iic.ParseOptions{})
+	if err != nil {
+		return err
+	}
+	b, err := iic.ParseFile(flags.Arg(1), iic.ParseOptions{})
+	if err != nil {
+		return err
+	}
+
+	diffs := iic.DiffFields(a, b)
+	if len(diffs) == 0 {
+		fmt.Println("IIC fields are identical")
+		return nil
+	}
+	for _, d := range diffs {
+		fmt.Printf("%s: %q != %q", d.Field, d.A, d.B)
+		if len(d.Note) > 0 {
+			fmt.Printf(" (%s)", d.Note)
+		}
+		fmt.Println()
+	}
+	return nil
+}
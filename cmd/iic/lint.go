@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/beevik/etree"
+	"github.com/noshto/iic"
+)
+
+// lintResult represents lint report of a single file
+type lintResult struct {
+	File string `json:"File"`
+	iic.LintReport
+}
+
+// lint checks documents matching the glob for common IIC mistakes without signing them.
+// Fails if any file has errors, warnings alone don't fail
+func lint(args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	glob := flags.String("glob", "*.xml", "pattern of files to check")
+	asJSON := flags.Bool("json", false, "print report as JSON")
+	flags.Parse(args)
+
+	files, err := filepath.Glob(*glob)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no files match %s", *glob)
+	}
+
+	results := make([]lintResult, len(files))
+	failed := 0
+	for i, file := range files {
+		results[i] = lintResult{File: file, LintReport: lintFile(file)}
+		if len(results[i].Errors) > 0 {
+			failed++
+		}
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		for _, result := range results {
+			for _, e := range result.Errors {
+				fmt.Printf("%s: error: %s\n", result.File, e)
+			}
+			for _, w := range result.Warnings {
+				fmt.Printf("%s: warning: %s\n", result.File, w)
+			}
+		}
+		fmt.Printf("%d files checked, %d with errors\n", len(files), failed)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("lint found errors in %d files", failed)
+	}
+	return nil
+}
+
+// lintFile reads and checks single file
+func lintFile(file string) iic.LintReport {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromFile(file); err != nil {
+		return iic.LintReport{Errors: []string{err.Error()}, Warnings: []string{}}
+	}
+	return iic.LintDocument(doc, iic.ParseOptions{}, iic.ValidateOptions{})
+}
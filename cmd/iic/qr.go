@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/noshto/iic"
+	"github.com/noshto/iic/qr"
+)
+
+// qrcode renders verification QR code of every invoice of a signed document.
+// SVG is written if the output has .svg extension, PNG otherwise
+func qrcode(args []string) error {
+	flags := flag.NewFlagSet("qr", flag.ExitOnError)
+	in := flags.String("in", "", "path to signed invoice")
+	out := flags.String("out", "qr.png", "path to QR image, numbered when the document has several invoices")
+	size := flags.Int("size", 256, "size of the image")
+	flags.Parse(args)
+
+	invoices, err := iic.ReadSignedInvoices(*in)
+	if err != nil {
+		return err
+	}
+
+	for i, invoice := range invoices {
+		path := *out
+		if len(invoices) > 1 {
+			ext := filepath.Ext(path)
+			path = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), i+1, ext)
+		}
+		if err := writeQRCode(path, invoice.VerificationURL(), *size); err != nil {
+			return err
+		}
+		fmt.Println(path)
+	}
+	return nil
+}
+
+// writeQRCode writes QR code of content into file
+func writeQRCode(path string, content string, size int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".svg") {
+		err = qr.WriteQRCodeSVG(file, content, size)
+	} else {
+		err = qr.WriteQRCode(file, content, size)
+	}
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
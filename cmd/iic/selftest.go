@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/noshto/iic"
+)
+
+// selftest signs a known test vector with the configured token and verifies it
+func selftest(args []string) error {
+	flags := flag.NewFlagSet("selftest", flag.ExitOnError)
+	signerFlags := addSignerFlags(flags)
+	flags.Parse(args)
+
+	signer, err := signerFlags.initialize()
+	if err != nil {
+		return err
+	}
+	defer signer.Finalize()
+
+	cert, err := signer.GetCertificate()
+	if err != nil {
+		return err
+	}
+	info := iic.InfoOfCertificate(&cert)
+	fmt.Printf("Subject: %s\nTIN: %s\nExpires: %s\n", info.Subject, info.TIN, info.NotAfter)
+
+	if err := iic.SelfTest(signer); err != nil {
+		return err
+	}
+	fmt.Println("OK")
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"unicode/utf8"
+
+	"github.com/beevik/etree"
+	"github.com/noshto/iic"
+)
+
+// position is a 1-based line and column of the input, columns count characters
+type position struct {
+	line int
+	col  int
+}
+
+func (p position) String() string {
+	return fmt.Sprintf("%d:%d", p.line, p.col)
+}
+
+// validate checks single document, read from stdin if the file is -, with the aggregate validator without
+// signing it. Every problem is printed to stdout as a line of the stable format
+//
+//	LINE:COL: MESSAGE
+//
+// e.g. for a problem matcher of an editor. LINE and COL are 1-based, COL counts characters. Problems of TIN
+// point to the start tag of Seller, syntax errors to where reading stopped, other problems to the start tag
+// of the validated Invoice, or 1:1 if there's none. Fails if any problem is found
+func validate(args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	tcrOptional := flags.Bool("tcr-optional", false, "accept empty TCRCode")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: iic validate [flags] file.xml|-")
+	}
+
+	var buf []byte
+	var err error
+	if flags.Arg(0) == "-" {
+		buf, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		buf, err = ioutil.ReadFile(flags.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	diagnostics := validateBytes(bytes.TrimPrefix(buf, []byte{0xEF, 0xBB, 0xBF}), iic.ValidateOptions{TCRCodeOptional: *tcrOptional})
+	for _, diagnostic := range diagnostics {
+		fmt.Println(diagnostic)
+	}
+	if len(diagnostics) > 0 {
+		return fmt.Errorf("validate found %d problems", len(diagnostics))
+	}
+	return nil
+}
+
+// validateBytes returns diagnostics of the document in buf in the format of validate
+func validateBytes(buf []byte, opts iic.ValidateOptions) []string {
+	invoice, seller, err := locate(buf)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(buf); err != nil {
+		return []string{fmt.Sprintf("%s: %v", position{1, 1}, err)}
+	}
+	err = iic.ValidateDocument(doc, iic.ParseOptions{}, opts)
+	if err == nil {
+		return nil
+	}
+	errs := []error{err}
+	var validationErr *iic.ValidationError
+	if errors.As(err, &validationErr) {
+		errs = validationErr.Errors
+	}
+
+	diagnostics := make([]string, len(errs))
+	for i, err := range errs {
+		at := invoice
+		if iic.ValidatorOf(err) == iic.ValidatorTIN {
+			at = seller
+		}
+		diagnostics[i] = fmt.Sprintf("%s: %v", at, err)
+	}
+	return diagnostics
+}
+
+// locate returns positions of the start tags of the first Invoice, the shallowest one like the validator finds it,
+// and of its Seller, or of the first Seller of the document if the invoice has none. Missing elements are at 1:1.
+// Fails with a diagnostic if buf isn't well-formed XML
+func locate(buf []byte) (position, position, error) {
+	type start struct {
+		at      position
+		depth   int
+		invoice int
+	}
+	invoices, sellers := []start{}, []start{}
+	enclosing := []int{}
+	depth := 0
+
+	decoder := xml.NewDecoder(bytes.NewReader(buf))
+	decoder.Strict = true
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			message := err.Error()
+			var syntaxErr *xml.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				message = syntaxErr.Msg
+			}
+			return position{}, position{}, fmt.Errorf("%s: %s", positionOf(buf, decoder.InputOffset()), message)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			depth++
+			invoice := -1
+			if len(enclosing) > 0 {
+				invoice = enclosing[len(enclosing)-1]
+			}
+			switch token.Name.Local {
+			case "Invoice":
+				invoices = append(invoices, start{at: positionOf(buf, offset), depth: depth})
+				invoice = len(invoices) - 1
+			case "Seller":
+				sellers = append(sellers, start{at: positionOf(buf, offset), depth: depth, invoice: invoice})
+			}
+			enclosing = append(enclosing, invoice)
+		case xml.EndElement:
+			depth--
+			if len(enclosing) > 0 {
+				enclosing = enclosing[:len(enclosing)-1]
+			}
+		}
+	}
+	if depth != 0 {
+		return position{}, position{}, fmt.Errorf("%s: unexpected EOF", positionOf(buf, int64(len(buf))))
+	}
+
+	invoice, seller := position{1, 1}, position{1, 1}
+	chosen := -1
+	for i, candidate := range invoices {
+		if chosen < 0 || candidate.depth < invoices[chosen].depth {
+			chosen = i
+		}
+	}
+	if chosen >= 0 {
+		invoice = invoices[chosen].at
+	}
+	own := -1
+	for i, candidate := range sellers {
+		if chosen >= 0 && candidate.invoice == chosen && (own < 0 || candidate.depth < sellers[own].depth) {
+			own = i
+		}
+	}
+	if own >= 0 {
+		seller = sellers[own].at
+	} else if len(sellers) > 0 {
+		seller = sellers[0].at
+	}
+	return invoice, seller, nil
+}
+
+// positionOf returns position of the byte offset of buf
+func positionOf(buf []byte, offset int64) position {
+	if offset > int64(len(buf)) {
+		offset = int64(len(buf))
+	}
+	before := buf[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	if i := bytes.LastIndexByte(before, '\n'); i >= 0 {
+		before = before[i+1:]
+	}
+	return position{line: line, col: utf8.RuneCount(before) + 1}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/noshto/iic"
+)
+
+// fileState is size and modification time of a file seen in the inbox
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// watch signs every .xml file dropped into the inbox with single signer session, writing the result into
+// the outbox and removing the input. Files which can't be signed are moved into the error folder.
+// The inbox is polled, so it works on network shares too. A file is picked up once its size and
+// modification time didn't change between two polls, so partially written files aren't signed.
+// Errors of single files, and of polling, e.g. when a network share is briefly unavailable, are logged
+// and watching continues. A file which is handled but can't be removed or moved is left in the inbox
+// and skipped until it changes, so it's never signed twice. The signer is configured with --config and
+// --pin-prompt like in other commands, e.g. iic watch --dir ./inbox --out ./outbox --config c.json
+func watch(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	dir := flags.String("dir", "inbox", "directory to watch for invoices")
+	out := flags.String("out", "outbox", "directory for signed invoices")
+	errDir := flags.String("errors", "errors", "directory for invoices which can't be signed")
+	interval := flags.Duration("interval", time.Second, "polling interval")
+	signerFlags := addSignerFlags(flags)
+	flags.Parse(args)
+
+	for _, path := range []string{*out, *errDir} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+	}
+
+	signer, err := signerFlags.initialize()
+	if err != nil {
+		return err
+	}
+	defer signer.Finalize()
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	logger.Printf("watching %s, signing with configuration %s", *dir, *signerFlags.configPath)
+	seen := map[string]fileState{}
+	stuck := map[string]fileState{}
+	for {
+		select {
+		case <-interrupt:
+			logger.Printf("stopped")
+			return nil
+		case <-ticker.C:
+		}
+
+		infos, err := ioutil.ReadDir(*dir)
+		if err != nil {
+			logger.Printf("%s: %v", *dir, err)
+			continue
+		}
+		current := map[string]fileState{}
+		left := map[string]fileState{}
+		for _, info := range infos {
+			if info.IsDir() || !strings.EqualFold(filepath.Ext(info.Name()), ".xml") {
+				continue
+			}
+			state := fileState{size: info.Size(), modTime: info.ModTime()}
+			if stuck[info.Name()] == state {
+				left[info.Name()] = state
+				continue
+			}
+			if seen[info.Name()] != state {
+				current[info.Name()] = state
+				continue
+			}
+
+			in := filepath.Join(*dir, info.Name())
+			params := &iic.Params{Signer: signer, InFile: in, OutFile: filepath.Join(*out, info.Name()), Logger: logger}
+			if err := iic.WriteIIC(params); err != nil {
+				logger.Printf("%s: %v", in, err)
+				if err := os.Rename(in, filepath.Join(*errDir, info.Name())); err != nil {
+					logger.Printf("%s: can't move into %s, skipping until it changes: %v", in, *errDir, err)
+					left[info.Name()] = state
+				}
+				continue
+			}
+			logger.Printf("%s: signed into %s", in, params.OutFile)
+			if err := os.Remove(in); err != nil {
+				logger.Printf("%s: can't remove, skipping until it changes: %v", in, err)
+				left[info.Name()] = state
+			}
+		}
+		seen, stuck = current, left
+	}
+}
@@ -0,0 +1,62 @@
+package iic
+
+import (
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// plainCommentPrefix starts comments inserted by Params.PlainComment
+const plainCommentPrefix = " plain IIC: "
+
+// setPlainComment inserts the plain IIC string of params as a comment just above the invoice,
+// replacing one inserted earlier
+func setPlainComment(invoice *etree.Element, params [7]string) {
+	parent := invoice.Parent()
+	if parent == nil {
+		return
+	}
+	for i := invoice.Index() - 1; i >= 0; i-- {
+		if data, ok := parent.Child[i].(*etree.CharData); ok && data.IsWhitespace() {
+			continue
+		}
+		if comment, ok := parent.Child[i].(*etree.Comment); ok && strings.HasPrefix(comment.Data, plainCommentPrefix) {
+			parent.RemoveChildAt(i)
+		}
+		break
+	}
+	data := strings.Replace(PlainIIC(params), "--", "- -", -1)
+	parent.InsertChildAt(invoice.Index(), etree.NewComment(plainCommentPrefix+data+" "))
+}
+
+// setPlainComments inserts plain IIC comments above invoices of doc which were signed
+func setPlainComments(doc *etree.Document, results []InvoiceResult) {
+	invoices := doc.FindElements("//Invoice")
+	for _, result := range results {
+		if result.Status == InvoiceSigned {
+			setPlainComment(invoices[result.Index], result.Fields)
+		}
+	}
+}
+
+// StripPlainComments removes plain IIC comments inserted by Params.PlainComment from doc,
+// e.g. before it's uploaded. Returns number of removed comments
+func StripPlainComments(doc *etree.Document) int {
+	removed := 0
+	var strip func(elem *etree.Element)
+	strip = func(elem *etree.Element) {
+		for i := len(elem.Child) - 1; i >= 0; i-- {
+			switch child := elem.Child[i].(type) {
+			case *etree.Comment:
+				if strings.HasPrefix(child.Data, plainCommentPrefix) {
+					elem.RemoveChildAt(i)
+					removed++
+				}
+			case *etree.Element:
+				strip(child)
+			}
+		}
+	}
+	strip(&doc.Element)
+	return removed
+}
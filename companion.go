@@ -0,0 +1,91 @@
+package iic
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// fieldRef references an attribute of an element found by path
+type fieldRef struct {
+	elem string
+	attr string
+}
+
+func (f fieldRef) String() string {
+	return strings.TrimPrefix(f.elem, "//") + "/" + f.attr
+}
+
+// companionFields lists fields which don't enter the IIC, but are cross-checked by the authority
+// for invoices of given TypeOfInv
+var companionFields = map[string][]fieldRef{
+	"CASH": {
+		{"//Invoice", "TCRCode"},
+		{"//Invoice", "OperatorCode"},
+		{"//PayMethod", "Type"},
+		{"//PayMethod", "Amt"},
+	},
+	"NONCASH": {
+		{"//Invoice", "OperatorCode"},
+		{"//PayMethod", "Type"},
+		{"//PayMethod", "Amt"},
+		{"//Buyer", "IDType"},
+		{"//Buyer", "IDNum"},
+		{"//Buyer", "Name"},
+	},
+}
+
+// ValidateCompanionFields checks that fields required for the detected TypeOfInv are present,
+// and that amounts of payment methods sum up to TotPrice. Documents without TypeOfInv are not checked
+func ValidateCompanionFields(doc *etree.Document) error {
+	typeOfInv, err := attributeOfElement("//Invoice", "TypeOfInv", doc)
+	if err != nil {
+		return nil
+	}
+	fields, ok := companionFields[typeOfInv]
+	if !ok {
+		return documentError(fmt.Errorf("unknown TypeOfInv %s", typeOfInv))
+	}
+
+	missing := []string{}
+	for _, field := range fields {
+		value, err := attributeOfElement(field.elem, field.attr, doc)
+		if err != nil || len(value) == 0 {
+			missing = append(missing, field.String())
+		}
+	}
+	if len(missing) > 0 {
+		return documentError(fmt.Errorf("%s invoice is missing required fields: %s", typeOfInv, strings.Join(missing, ", ")))
+	}
+
+	return validatePayMethods(doc)
+}
+
+// validatePayMethods checks that sum of PayMethod amounts equals TotPrice
+func validatePayMethods(doc *etree.Document) error {
+	totPrice, err := attributeOfElement("//Invoice", "TotPrice", doc)
+	if err != nil {
+		return documentError(err)
+	}
+	total, err := strconv.ParseFloat(totPrice, 64)
+	if err != nil {
+		return documentError(fmt.Errorf("TotPrice %s is not a number", totPrice))
+	}
+
+	sum := 0.0
+	for _, payMethod := range doc.FindElements("//PayMethod") {
+		amt := payMethod.SelectAttrValue("Amt", "")
+		value, err := strconv.ParseFloat(amt, 64)
+		if err != nil {
+			return documentError(fmt.Errorf("PayMethod/Amt %s is not a number", amt))
+		}
+		sum += value
+	}
+	if math.Abs(sum-total) >= 0.005 {
+		return documentError(fmt.Errorf("PayMethod amounts sum up to %.2f, but TotPrice is %s", sum, totPrice))
+	}
+	return nil
+}
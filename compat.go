@@ -0,0 +1,67 @@
+package iic
+
+import (
+	"fmt"
+
+	"github.com/beevik/etree"
+)
+
+// CompatResult represents comparison of IIC found in an Invoice of a signed file, e.g. produced by a prior
+// implementation, with IIC this library generates for the same invoice. Index is position of the invoice
+// among Invoice elements of the file
+type CompatResult struct {
+	File     string
+	Index    int
+	Fields   [7]string
+	PlainIIC string
+	OldIIC   string
+	NewIIC   string
+	Err      error
+}
+
+// Match reports whether the invoice was processed and both IICs are equal
+func (r CompatResult) Match() bool {
+	return r.Err == nil && r.OldIIC == r.NewIIC
+}
+
+// CompareCorpus generates IIC for every Invoice of signed files with signer and compares it with IIC found in them.
+// RSASSA-PKCS1-v1_5 is deterministic, so with the key which signed the files any mismatch means that values are
+// read or formatted differently, see PlainIIC of the result. Values are read verbatim as in earlier releases,
+// which differ only for documents with several Seller elements: they used the first Seller of the document,
+// now the Seller of the invoice is used, see ParseOptions. Results are in order of files and invoices
+func CompareCorpus(signer Signer, files []string, opts ParseOptions) []CompatResult {
+	results := []CompatResult{}
+	for _, file := range files {
+		doc, _, err := readDocument(file)
+		if err != nil {
+			results = append(results, CompatResult{File: file, Err: documentError(err)})
+			continue
+		}
+		invoices := doc.FindElements("//Invoice")
+		if len(invoices) == 0 {
+			results = append(results, CompatResult{File: file, Err: documentError(fmt.Errorf("can't find element %s", "//Invoice"))})
+			continue
+		}
+		for i, invoice := range invoices {
+			results = append(results, compareInvoice(signer, file, i, doc, invoice, opts))
+		}
+	}
+	return results
+}
+
+// compareInvoice compares IIC of single invoice of doc read from file
+func compareInvoice(signer Signer, file string, index int, doc *etree.Document, invoice *etree.Element, opts ParseOptions) CompatResult {
+	result := CompatResult{File: file, Index: index}
+	parsed, err := parseInvoice(doc, invoice, opts)
+	if err != nil {
+		result.Err = documentError(fmt.Errorf("invoice %d: %v", index+1, err))
+		return result
+	}
+	result.Fields, result.PlainIIC = parsed, PlainIIC(parsed)
+	if result.OldIIC, _, err = iicOf(invoice); err != nil {
+		result.Err = documentError(fmt.Errorf("invoice %d: %v", index+1, err))
+		return result
+	}
+	result.NewIIC, _, result.Err = generateIIC(signer, parsed)
+	return result
+}
@@ -0,0 +1,54 @@
+package iic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// ConvertRepresentation returns copy of doc with values of the IIC of every Invoice and its Seller moved into
+// target representation: attributes for FieldAttributes, child elements for FieldElements, see FieldMode.
+// Only values read for the IIC are moved; IIC and IICSignature are left as they are, see IICPlacement
+func ConvertRepresentation(doc *etree.Document, target FieldMode) (*etree.Document, error) {
+	if target != FieldAttributes && target != FieldElements {
+		return nil, fmt.Errorf("can't convert into FieldMode %d", target)
+	}
+	converted := doc.Copy()
+	invoices := converted.FindElements("//Invoice")
+	if len(invoices) == 0 {
+		return nil, documentError(fmt.Errorf("can't find element %s", "//Invoice"))
+	}
+
+	for _, invoice := range invoices {
+		for _, name := range []string{"IssueDateTime", "IssueDate", "IssueTime", "InvOrdNum", "BusinUnitCode", "TCRCode", "SoftCode", "TotPrice"} {
+			convertValue(invoice, name, target)
+		}
+		if seller, err := sellerOf(converted, invoice); err == nil {
+			convertValue(seller, "IDNum", target)
+			convertValue(seller, "VATNumber", target)
+		}
+	}
+	return converted, nil
+}
+
+// convertValue moves value with given name of elem between its attribute and child element
+func convertValue(elem *etree.Element, name string, target FieldMode) {
+	switch target {
+	case FieldElements:
+		attr := elem.SelectAttr(name)
+		if attr == nil || elem.SelectElement(name) != nil {
+			return
+		}
+		value := attr.Value
+		elem.RemoveAttr(name)
+		elem.CreateElement(qualified(elem, name)).SetText(value)
+	case FieldAttributes:
+		child := elem.SelectElement(name)
+		if child == nil || elem.SelectAttr(name) != nil {
+			return
+		}
+		elem.RemoveChild(child)
+		elem.CreateAttr(name, strings.TrimSpace(child.Text()))
+	}
+}
@@ -0,0 +1,52 @@
+package iic
+
+import (
+	"testing"
+
+	"github.com/beevik/etree"
+)
+
+func TestConvertRepresentationRoundTrip(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	tests := []struct {
+		name    string
+		content string
+		from    FieldMode
+		to      FieldMode
+	}{
+		{"attributes to elements", testInvoice, FieldAttributes, FieldElements},
+		{"elements to attributes", testElementInvoice, FieldElements, FieldAttributes},
+	}
+	for _, test := range tests {
+		original := readTestDocument(t, test.content)
+		converted, err := ConvertRepresentation(original, test.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := ConvertRepresentation(converted, test.from)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		IICs := []string{}
+		for _, doc := range []struct {
+			doc  *etree.Document
+			mode FieldMode
+		}{{original, test.from}, {converted, test.to}, {back, test.from}} {
+			staged, err := Stage(signer, doc.doc, &Params{ParseOptions: ParseOptions{FieldMode: doc.mode}})
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if fields := staged.Results[0].Fields; fields != testInvoiceFields {
+				t.Errorf("%s: fields are %v, want %v", test.name, fields, testInvoiceFields)
+			}
+			IICs = append(IICs, staged.Results[0].IIC)
+		}
+		if IICs[0] != IICs[1] || IICs[0] != IICs[2] {
+			t.Errorf("%s: IICs differ: %v", test.name, IICs)
+		}
+		if _, err := parse(converted, ParseOptions{FieldMode: test.from}); err == nil {
+			t.Errorf("%s: converted document still has values in the original representation", test.name)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package iic
+
+// FieldNames lists names of IIC fields in the order of GenerateIIC parameters
+var FieldNames = [7]string{"TIN", "IssueDateTime", "InvOrdNum", "BusinUnitCode", "TCRCode", "SoftCode", "TotPrice"}
+
+// FieldDiff represents a field which has different values in two invoices.
+// Note explains the difference when it's subtle, e.g. same instant in different timezones
+type FieldDiff struct {
+	Field string
+	A     string
+	B     string
+	Note  string
+}
+
+// DiffFields returns fields which differ between a and b
+func DiffFields(a, b [7]string) []FieldDiff {
+	diffs := []FieldDiff{}
+	for i := range a {
+		if a[i] == b[i] {
+			continue
+		}
+		diffs = append(diffs, FieldDiff{
+			Field: FieldNames[i],
+			A:     a[i],
+			B:     b[i],
+			Note:  noteOfDiff(i, a[i], b[i]),
+		})
+	}
+	return diffs
+}
+
+// noteOfDiff explains difference of values of the i-th field
+func noteOfDiff(i int, a string, b string) string {
+	if FieldNames[i] != "IssueDateTime" {
+		return ""
+	}
+	timeA, errA := parseDateTime(a)
+	timeB, errB := parseDateTime(b)
+	if errA != nil || errB != nil {
+		return ""
+	}
+	if timeA.Equal(timeB) {
+		return "same instant, different timezone or format"
+	}
+	return "differs by " + timeB.Sub(timeA).String()
+}
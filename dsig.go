@@ -0,0 +1,37 @@
+package iic
+
+import (
+	"fmt"
+
+	"github.com/beevik/etree"
+)
+
+// xmldsigNamespace is the namespace of XML-DSIG elements
+const xmldsigNamespace = "http://www.w3.org/2000/09/xmldsig#"
+
+// findSignatures returns XML-DSIG Signature elements of doc
+func findSignatures(doc *etree.Document) []*etree.Element {
+	signatures := []*etree.Element{}
+	for _, elem := range doc.FindElements("//Signature") {
+		if elem.NamespaceURI() == xmldsigNamespace {
+			signatures = append(signatures, elem)
+		}
+	}
+	return signatures
+}
+
+// handleSignatures fails with ErrSignaturePresent if doc has XML-DSIG signature, which adding IIC would
+// invalidate. If remove is set, signatures are removed instead, so the document can be signed again
+func handleSignatures(doc *etree.Document, remove bool) error {
+	signatures := findSignatures(doc)
+	if len(signatures) == 0 {
+		return nil
+	}
+	if !remove {
+		return fmt.Errorf("%w: %s", ErrSignaturePresent, signatures[0].GetPath())
+	}
+	for _, signature := range signatures {
+		signature.Parent().RemoveChild(signature)
+	}
+	return nil
+}
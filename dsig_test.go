@@ -0,0 +1,49 @@
+package iic
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testSignedInvoice is testInvoice enveloped by an XML-DSIG signature
+var testSignedInvoice = strings.Replace(testInvoice, "</RegisterInvoiceRequest>",
+	`  <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo/><ds:SignatureValue>AA==</ds:SignatureValue></ds:Signature>
+</RegisterInvoiceRequest>`, 1)
+
+func TestWriteIICSignaturePresent(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	in := writeTestFile(t, "in.xml", testSignedInvoice)
+	out := filepath.Join(t.TempDir(), "out.xml")
+
+	err := WriteIIC(&Params{Signer: signer, InFile: in, OutFile: out})
+	if !errors.Is(err, ErrSignaturePresent) {
+		t.Fatalf("WriteIIC returned %v, want ErrSignaturePresent", err)
+	}
+	if _, _, err := readDocument(out); err == nil {
+		t.Error("output is written despite the signature")
+	}
+
+	if err := WriteIIC(&Params{Signer: signer, InFile: in, OutFile: out, RemoveSignature: true}); err != nil {
+		t.Fatal(err)
+	}
+	doc, _, err := readDocument(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signatures := findSignatures(doc); len(signatures) != 0 {
+		t.Errorf("output has %d signatures", len(signatures))
+	}
+	if err := verifyTestDocument(t, signer, doc); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFindSignaturesNamespace(t *testing.T) {
+	// a Signature outside of the XML-DSIG namespace isn't invalidated by the IIC
+	doc := readTestDocument(t, strings.Replace(testInvoice, "</RegisterInvoiceRequest>", "<Signature/></RegisterInvoiceRequest>", 1))
+	if signatures := findSignatures(doc); len(signatures) != 0 {
+		t.Errorf("found %d signatures", len(signatures))
+	}
+}
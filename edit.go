@@ -0,0 +1,177 @@
+package iic
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/beevik/etree"
+)
+
+// EditAndSign sets given attributes of the Invoice of inFile, validates values of the IIC and saves
+// the document with recomputed IIC to outFile. IIC and IICSignature themselves can't be edited
+func EditAndSign(signer Signer, inFile string, outFile string, edits map[string]string) error {
+	doc, hasBOM, err := readDocument(inFile)
+	if err != nil {
+		return documentError(err)
+	}
+
+	params := &Params{Signer: signer, InFile: inFile, OutFile: outFile, Validate: true}
+	edited, _, _, err := editAndSign(signer, doc, edits, params)
+	if err != nil {
+		return err
+	}
+	formatDocument(edited, params.OutputStyle)
+	return writeDocument(edited, outFile, hasBOM, params)
+}
+
+// editAndSign applies edits to a copy of doc and generates its IIC, so doc is left untouched if either fails.
+// Returns the signed copy
+func editAndSign(signer Signer, doc *etree.Document, edits map[string]string, params *Params) (*etree.Document, string, string, error) {
+	edited := doc.Copy()
+	if err := applyEdits(edited, edits); err != nil {
+		return nil, "", "", documentError(err)
+	}
+	_, IIC, IICSignature, err := signDocument(signer, edited, params)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return edited, IIC, IICSignature, nil
+}
+
+// replaceDocument moves content of with into doc, so doc pointers of the caller see it.
+// Elements of doc obtained before are detached from it
+func replaceDocument(doc *etree.Document, with *etree.Document) {
+	for _, token := range append([]etree.Token{}, doc.Child...) {
+		doc.RemoveChild(token)
+	}
+	for _, token := range append([]etree.Token{}, with.Child...) {
+		doc.AddChild(token)
+	}
+}
+
+// applyEdits sets given attributes of the first Invoice of doc in order of their names
+func applyEdits(doc *etree.Document, edits map[string]string) error {
+	invoice := doc.FindElement("//Invoice")
+	if invoice == nil {
+		return fmt.Errorf("can't find element %s", "//Invoice")
+	}
+
+	names := make([]string, 0, len(edits))
+	for name := range edits {
+		if name == "IIC" || name == "IICSignature" {
+			return fmt.Errorf("attribute %s is computed and can't be edited", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		invoice.CreateAttr(name, edits[name])
+	}
+	return nil
+}
+
+// Correction represents IIC of an invoice before and after CorrectInvoice, for audit
+type Correction struct {
+	Fields          InvoiceFields
+	OldIIC          string
+	OldIICSignature string
+	IIC             string
+	IICSignature    string
+}
+
+// CorrectInvoice writes corrected values into the first Invoice of signed doc and recomputes its IIC.
+// A corrected invoice must keep its ordinal and seller, so changing InvOrdNum or TIN is an error.
+// Values are validated before the IIC is generated. Doc is left untouched if the correction fails, otherwise
+// its content is replaced, so elements of doc obtained before are stale
+func CorrectInvoice(signer Signer, doc *etree.Document, corrected InvoiceFields) (Correction, error) {
+	current, err := parse(doc, ParseOptions{})
+	if err != nil {
+		return Correction{}, documentError(err)
+	}
+	if corrected.InvOrdNum != current[2] {
+		return Correction{}, documentError(fmt.Errorf("correction can't change InvOrdNum %s to %s", current[2], corrected.InvOrdNum))
+	}
+	if corrected.TIN != current[0] {
+		return Correction{}, documentError(fmt.Errorf("correction can't change TIN %s to %s", current[0], corrected.TIN))
+	}
+	oldIIC, oldIICSignature, err := ReadIIC(doc)
+	if err != nil {
+		return Correction{}, err
+	}
+
+	edits := map[string]string{}
+	for _, diff := range DiffFields(current, corrected.Array()) {
+		edits[diff.Field] = diff.B
+	}
+	params := &Params{Signer: signer, Validate: true}
+	edited, IIC, IICSignature, err := editAndSign(signer, doc, edits, params)
+	if err != nil {
+		return Correction{}, err
+	}
+	replaceDocument(doc, edited)
+	return Correction{
+		Fields:          corrected,
+		OldIIC:          oldIIC,
+		OldIICSignature: oldIICSignature,
+		IIC:             IIC,
+		IICSignature:    IICSignature,
+	}, nil
+}
+
+// SoftCodeChange represents an invoice re-signed by ResignSoftCode with its previous SoftCode and IIC, for audit.
+// Index is position of the invoice among Invoice elements of the document
+type SoftCodeChange struct {
+	Index           int
+	OldSoftCode     string
+	OldIIC          string
+	OldIICSignature string
+	Fields          InvoiceFields
+	IIC             string
+	IICSignature    string
+}
+
+// ResignSoftCode replaces SoftCode of every Invoice of signed doc with softCode, e.g. after the software is
+// re-registered, and regenerates their IIC. Returns previous and new SoftCode and IIC of every invoice for
+// reconciliation. Invalid softCode is an error, and doc is left untouched if any invoice can't be re-signed
+func ResignSoftCode(signer Signer, doc *etree.Document, softCode string) ([]SoftCodeChange, error) {
+	if err := ValidateSoftCode(softCode); err != nil {
+		return nil, documentError(err)
+	}
+	invoices := doc.FindElements("//Invoice")
+	if len(invoices) == 0 {
+		return nil, documentError(fmt.Errorf("can't find element %s", "//Invoice"))
+	}
+
+	changes := make([]SoftCodeChange, len(invoices))
+	for i, invoice := range invoices {
+		parsed, err := parseInvoice(doc, invoice, ParseOptions{})
+		if err != nil {
+			return nil, documentError(fmt.Errorf("invoice %d: %v", i+1, err))
+		}
+		oldIIC, oldIICSignature, err := iicOf(invoice)
+		if err != nil {
+			return nil, documentError(fmt.Errorf("invoice %d: %v", i+1, err))
+		}
+
+		parsed[5] = softCode
+		IIC, IICSignature, err := generateIIC(signer, parsed)
+		if err != nil {
+			return nil, fmt.Errorf("invoice %d: %w", i+1, err)
+		}
+		changes[i] = SoftCodeChange{
+			Index:           i,
+			OldSoftCode:     invoice.SelectAttrValue("SoftCode", ""),
+			OldIIC:          oldIIC,
+			OldIICSignature: oldIICSignature,
+			Fields:          FieldsOf(parsed),
+			IIC:             IIC,
+			IICSignature:    IICSignature,
+		}
+	}
+
+	for i, invoice := range invoices {
+		invoice.CreateAttr("SoftCode", softCode)
+		setIICAs(invoice, changes[i].IIC, changes[i].IICSignature, placementOf(invoice))
+	}
+	return changes, nil
+}
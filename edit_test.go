@@ -0,0 +1,47 @@
+package iic
+
+import (
+	"testing"
+)
+
+func TestCorrectInvoice(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	doc := readTestDocument(t, testInvoice)
+	if _, _, _, err := signDocument(signer, doc, &Params{Signer: signer}); err != nil {
+		t.Fatal(err)
+	}
+
+	corrected := FieldsOf(testInvoiceFields)
+	corrected.TotPrice = "100.00"
+	correction, err := CorrectInvoice(signer, doc, corrected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if correction.IIC == correction.OldIIC {
+		t.Errorf("IIC %s isn't recomputed", correction.IIC)
+	}
+	if got := doc.FindElement("//Invoice").SelectAttrValue("TotPrice", ""); got != "100.00" {
+		t.Errorf("TotPrice = %s, want 100.00", got)
+	}
+	if err := verifyTestDocument(t, signer, doc); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCorrectInvoiceFailureLeavesDocument(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	doc := readTestDocument(t, testInvoice)
+	if _, _, _, err := signDocument(signer, doc, &Params{Signer: signer}); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := doc.WriteToString()
+
+	corrected := FieldsOf(testInvoiceFields)
+	corrected.TotPrice = "not a number"
+	if _, err := CorrectInvoice(signer, doc, corrected); err == nil {
+		t.Fatal("invalid TotPrice is accepted")
+	}
+	if after, _ := doc.WriteToString(); after != before {
+		t.Errorf("failed correction changed the document:\n%s", after)
+	}
+}
@@ -0,0 +1,108 @@
+package iic
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/beevik/etree"
+)
+
+// OutputEncoding defines character encoding of saved documents. It doesn't affect the IIC, which is computed
+// from values of the parsed document
+type OutputEncoding int
+
+const (
+	// EncodingUTF8 saves documents in UTF-8
+	EncodingUTF8 OutputEncoding = iota
+	// EncodingWindows1250 saves documents in Windows-1250, expected by some legacy receipt printers and uploaders
+	EncodingWindows1250
+)
+
+// String returns name of the encoding as declared in the XML declaration
+func (e OutputEncoding) String() string {
+	switch e {
+	case EncodingUTF8:
+		return "UTF-8"
+	case EncodingWindows1250:
+		return "windows-1250"
+	default:
+		return "unknown"
+	}
+}
+
+// windows1250 maps bytes 0x80-0xFF of Windows-1250 to runes, U+FFFD marks undefined bytes
+var windows1250 = [128]rune{
+	0x20AC, 0xFFFD, 0x201A, 0xFFFD, 0x201E, 0x2026, 0x2020, 0x2021,
+	0xFFFD, 0x2030, 0x0160, 0x2039, 0x015A, 0x0164, 0x017D, 0x0179,
+	0xFFFD, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0xFFFD, 0x2122, 0x0161, 0x203A, 0x015B, 0x0165, 0x017E, 0x017A,
+	0x00A0, 0x02C7, 0x02D8, 0x0141, 0x00A4, 0x0104, 0x00A6, 0x00A7,
+	0x00A8, 0x00A9, 0x015E, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x017B,
+	0x00B0, 0x00B1, 0x02DB, 0x0142, 0x00B4, 0x00B5, 0x00B6, 0x00B7,
+	0x00B8, 0x0105, 0x015F, 0x00BB, 0x013D, 0x02DD, 0x013E, 0x017C,
+	0x0154, 0x00C1, 0x00C2, 0x0102, 0x00C4, 0x0139, 0x0106, 0x00C7,
+	0x010C, 0x00C9, 0x0118, 0x00CB, 0x011A, 0x00CD, 0x00CE, 0x010E,
+	0x0110, 0x0143, 0x0147, 0x00D3, 0x00D4, 0x0150, 0x00D6, 0x00D7,
+	0x0158, 0x016E, 0x00DA, 0x0170, 0x00DC, 0x00DD, 0x0162, 0x00DF,
+	0x0155, 0x00E1, 0x00E2, 0x0103, 0x00E4, 0x013A, 0x0107, 0x00E7,
+	0x010D, 0x00E9, 0x0119, 0x00EB, 0x011B, 0x00ED, 0x00EE, 0x010F,
+	0x0111, 0x0144, 0x0148, 0x00F3, 0x00F4, 0x0151, 0x00F6, 0x00F7,
+	0x0159, 0x016F, 0x00FA, 0x0171, 0x00FC, 0x00FD, 0x0163, 0x02D9,
+}
+
+// encodingRegexp matches encoding pseudo-attribute of the XML declaration
+var encodingRegexp = regexp.MustCompile(`encoding\s*=\s*("[^"]*"|'[^']*')`)
+
+// declareEncoding sets encoding of the XML declaration of doc, adding the declaration if it's missing
+func declareEncoding(doc *etree.Document, encoding OutputEncoding) {
+	declaration := fmt.Sprintf(`encoding="%s"`, encoding)
+	for _, token := range doc.Child {
+		if inst, ok := token.(*etree.ProcInst); ok && inst.Target == "xml" {
+			if encodingRegexp.MatchString(inst.Inst) {
+				inst.Inst = encodingRegexp.ReplaceAllLiteralString(inst.Inst, declaration)
+			} else {
+				inst.Inst += " " + declaration
+			}
+			return
+		}
+	}
+	doc.InsertChildAt(0, etree.NewProcInst("xml", `version="1.0" `+declaration))
+	doc.InsertChildAt(1, etree.NewText("\n"))
+}
+
+// encode transcodes UTF-8 buf into given encoding. Characters which can't be represented are reported
+// with their byte offset in buf
+func encode(buf []byte, encoding OutputEncoding) ([]byte, error) {
+	if encoding == EncodingUTF8 {
+		return buf, nil
+	}
+	if encoding != EncodingWindows1250 {
+		return nil, fmt.Errorf("unknown OutputEncoding %d", encoding)
+	}
+
+	encoded := make([]byte, 0, len(buf))
+	for offset := 0; offset < len(buf); {
+		r, size := utf8.DecodeRune(buf[offset:])
+		b, ok := windows1250Byte(r)
+		if !ok || r == utf8.RuneError {
+			return nil, fmt.Errorf("character %U at offset %d can't be represented in %s", r, offset, encoding)
+		}
+		encoded = append(encoded, b)
+		offset += size
+	}
+	return encoded, nil
+}
+
+// windows1250Byte returns Windows-1250 byte of r
+func windows1250Byte(r rune) (byte, bool) {
+	if r < 0x80 {
+		return byte(r), true
+	}
+	for i, c := range windows1250 {
+		if c == r && c != utf8.RuneError {
+			return byte(0x80 + i), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,69 @@
+package iic
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// Environment identifies the fiscalization environment the invoices are signed for
+type Environment int
+
+const (
+	// Production is the live environment, it's the default
+	Production Environment = iota
+	// Sandbox is the test environment, where Params.SandboxSigner may replace an unavailable token
+	Sandbox
+)
+
+// String returns name of the environment
+func (e Environment) String() string {
+	switch e {
+	case Production:
+		return "production"
+	case Sandbox:
+		return "sandbox"
+	default:
+		return "unknown"
+	}
+}
+
+// SoftwareSignerPEM creates signer of a software key, e.g. a test key for Params.SandboxSigner, from PEM encoded
+// PKCS#1 or PKCS#8 RSA private key and certificate
+func SoftwareSignerPEM(keyPEM []byte, certPEM []byte) (*CertifiedSigner, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("can't find private key in PEM data")
+	}
+	var key crypto.Signer
+	if rsaKey, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		key = rsaKey
+	} else {
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", parsed)
+		}
+		key = signer
+	}
+
+	keySigner, err := NewKeySigner(key, AlgorithmRSA)
+	if err != nil {
+		return nil, err
+	}
+	return NewCertifiedSignerPEM(keySigner, certPEM)
+}
+
+// sandboxFallback returns params.SandboxSigner in place of SafeNet which failed with err, if params allow it.
+// Otherwise err is returned
+func sandboxFallback(params *Params, err error) (Signer, error) {
+	if params.Environment != Sandbox || params.SandboxSigner == nil {
+		return nil, err
+	}
+	params.warnf(WarningSandbox, "using sandbox signer, as SafeNet is unavailable: %v", err)
+	return params.SandboxSigner, nil
+}
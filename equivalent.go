@@ -0,0 +1,131 @@
+package iic
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/beevik/etree"
+)
+
+// EquivalentIgnoringIIC reports whether a and b have the same canonical XML once IIC and IICSignature of every
+// Invoice are stripped, in either placement, e.g. to tell if re-signed output differs from the original in content.
+// Whitespace-only text between elements is ignored, like by DocumentFingerprint. See DiffIgnoringIIC for
+// the differences
+func EquivalentIgnoringIIC(a, b *etree.Document) (bool, error) {
+	diffs, err := DiffIgnoringIIC(a, b)
+	return len(diffs) == 0, err
+}
+
+// DiffIgnoringIIC is the same as EquivalentIgnoringIIC, but summarizes differences of a and b, one per line,
+// e.g. `/Invoice/Items/I[2]: attribute UPB "10.00" != "12.00"`. Returns none if they are equivalent
+func DiffIgnoringIIC(a, b *etree.Document) ([]string, error) {
+	strippedA, canonicalA, err := canonicalIgnoringIIC(a)
+	if err != nil {
+		return nil, err
+	}
+	strippedB, canonicalB, err := canonicalIgnoringIIC(b)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(canonicalA, canonicalB) {
+		return []string{}, nil
+	}
+
+	diffs := []string{}
+	if rootA, rootB := strippedA.Root(), strippedB.Root(); rootA != nil && rootB != nil {
+		diffElements("/"+rootA.FullTag(), rootA, rootB, &diffs)
+	}
+	if len(diffs) == 0 {
+		offset := 0
+		for offset < len(canonicalA) && offset < len(canonicalB) && canonicalA[offset] == canonicalB[offset] {
+			offset++
+		}
+		diffs = append(diffs, fmt.Sprintf("canonical XML differs at byte %d", offset))
+	}
+	return diffs, nil
+}
+
+// canonicalIgnoringIIC returns copy of doc without IIC, IICSignature and whitespace-only text between elements,
+// along with its canonical XML
+func canonicalIgnoringIIC(doc *etree.Document) (*etree.Document, []byte, error) {
+	stripped := doc.Copy()
+	for _, invoice := range stripped.FindElements("//Invoice") {
+		for _, name := range []string{"IIC", "IICSignature"} {
+			for _, child := range invoice.SelectElements(name) {
+				invoice.RemoveChild(child)
+			}
+			invoice.RemoveAttr(name)
+		}
+	}
+	stripWhitespace(&stripped.Element)
+	canonical, err := Canonicalize(stripped)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stripped, canonical, nil
+}
+
+// diffElements appends differences of elements a and b found at path, and of their descendants
+func diffElements(path string, a, b *etree.Element, diffs *[]string) {
+	if a.FullTag() != b.FullTag() {
+		*diffs = append(*diffs, fmt.Sprintf("%s: element %s != %s", path, a.FullTag(), b.FullTag()))
+		return
+	}
+
+	attrsA, attrsB := attrMap(a), attrMap(b)
+	for _, key := range sortedKeys(attrsA, attrsB) {
+		valueA, okA := attrsA[key]
+		valueB, okB := attrsB[key]
+		switch {
+		case !okA:
+			*diffs = append(*diffs, fmt.Sprintf("%s: attribute %s is only in the second document", path, key))
+		case !okB:
+			*diffs = append(*diffs, fmt.Sprintf("%s: attribute %s is only in the first document", path, key))
+		case valueA != valueB:
+			*diffs = append(*diffs, fmt.Sprintf("%s: attribute %s %q != %q", path, key, valueA, valueB))
+		}
+	}
+	if a.Text() != b.Text() {
+		*diffs = append(*diffs, fmt.Sprintf("%s: text %q != %q", path, a.Text(), b.Text()))
+	}
+
+	childrenA, childrenB := a.ChildElements(), b.ChildElements()
+	if len(childrenA) != len(childrenB) {
+		*diffs = append(*diffs, fmt.Sprintf("%s: %d child elements != %d", path, len(childrenA), len(childrenB)))
+	}
+	seen := map[string]int{}
+	for i := 0; i < len(childrenA) && i < len(childrenB); i++ {
+		tag := childrenA[i].FullTag()
+		seen[tag]++
+		childPath := path + "/" + tag
+		if seen[tag] > 1 {
+			childPath = fmt.Sprintf("%s[%d]", childPath, seen[tag])
+		}
+		diffElements(childPath, childrenA[i], childrenB[i], diffs)
+	}
+}
+
+// attrMap returns values of attributes of elem by their full keys
+func attrMap(elem *etree.Element) map[string]string {
+	attrs := make(map[string]string, len(elem.Attr))
+	for _, attr := range elem.Attr {
+		attrs[attr.FullKey()] = attr.Value
+	}
+	return attrs
+}
+
+// sortedKeys returns keys of both maps in ascending order
+func sortedKeys(a, b map[string]string) []string {
+	keys := []string{}
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
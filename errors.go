@@ -0,0 +1,89 @@
+package iic
+
+import "errors"
+
+var (
+	// ErrInvalidDocument classifies errors caused by unreadable documents or missing and malformed values
+	ErrInvalidDocument = errors.New("invalid document")
+	// ErrSigner classifies errors caused by the signer, e.g. token is removed or can't be initialized
+	ErrSigner = errors.New("signer failure")
+	// ErrSignerNotReady is returned when the token can't be opened within Params.InitTimeout
+	ErrSignerNotReady = errors.New("HSM not ready")
+	// ErrTokenAbsent is returned when the token is removed or its session is lost
+	ErrTokenAbsent = errors.New("token absent")
+	// ErrKeyUnusable is returned when the token is present, but its key can't produce a valid signature
+	ErrKeyUnusable = errors.New("key unusable")
+	// ErrBadPIN is returned when the token rejects the PIN. Don't retry with the same PIN,
+	// as repeated failures lock the token
+	ErrBadPIN = errors.New("PIN rejected")
+	// ErrTokenLocked is returned when the token is locked after too many wrong PINs and needs an operator
+	ErrTokenLocked = errors.New("token locked")
+	// ErrRetryBudgetExhausted is returned when a batch used up BatchParams.RetryBudget, which means that
+	// the signer fails systemically rather than occasionally
+	ErrRetryBudgetExhausted = errors.New("batch retry budget exhausted")
+
+	// ErrUnknownTIN is returned when CertRegistry has no certificate matching Seller TIN of the invoice
+	ErrUnknownTIN = errors.New("no certificate for TIN")
+
+	// ErrReplayed is returned when Params.SeenStore has already seen the ordinal of the invoice, which means
+	// that signing it would issue the same ordinal twice
+	ErrReplayed = errors.New("ordinal already signed")
+
+	// ErrMultipleInvoices is returned when the single-invoice API is given a document with several Invoice elements,
+	// which would leave all but the first unsigned
+	ErrMultipleInvoices = errors.New("document has several invoices")
+
+	// ErrSchemaVersion is returned when the document doesn't declare schema version required by Params.SchemaVersion
+	ErrSchemaVersion = errors.New("unsupported schema version")
+
+	// ErrSignaturePresent is returned when the document has XML-DSIG signature which adding IIC would invalidate
+	ErrSignaturePresent = errors.New("document already has XML-DSIG signature")
+
+	// ErrSignatureInvalid is returned when IICSignature isn't made with the certificate's key for given values
+	ErrSignatureInvalid = errors.New("IICSignature doesn't match the certificate")
+	// ErrIICMismatch is returned when IIC isn't md5 hash of IICSignature
+	ErrIICMismatch = errors.New("IIC doesn't match IICSignature")
+	// ErrCertificateNotValid is returned when the certificate wasn't valid at IssueDateTime
+	ErrCertificateNotValid = errors.New("certificate is not valid at IssueDateTime")
+	// ErrKeyUsage is returned when key usage of the certificate doesn't allow signing, see CheckKeyUsage
+	ErrKeyUsage = errors.New("certificate key usage doesn't allow signing")
+	// ErrUntrustedCertificate is returned when the certificate doesn't chain to a trusted root
+	ErrUntrustedCertificate = errors.New("certificate is not trusted")
+)
+
+// classifiedError attaches a class sentinel to an error, so it can be checked with errors.Is
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.class
+}
+
+// documentError classifies err as ErrInvalidDocument
+func documentError(err error) error {
+	return &classifiedError{class: ErrInvalidDocument, err: err}
+}
+
+// signerError classifies err as ErrSigner
+func signerError(err error) error {
+	return &classifiedError{class: ErrSigner, err: err}
+}
+
+// initError classifies error of signer initialization as ErrSigner, and as ErrBadPIN or ErrTokenLocked
+// if the token rejects the PIN
+func initError(err error) error {
+	if class := pinErrorClass(err); class != nil {
+		err = &classifiedError{class: class, err: err}
+	}
+	return signerError(err)
+}
@@ -0,0 +1,54 @@
+package iic
+
+import "time"
+
+// BatchEstimate represents planned workload of a batch: number of files and their invoices, and estimated
+// wall time of signing them in a single worker. Unreadable files are counted, but have no invoices
+type BatchEstimate struct {
+	Files      int
+	Invoices   int
+	Unreadable int
+	PerSign    time.Duration
+	Duration   time.Duration
+}
+
+// WithWorkers estimates wall time of signing the invoices in given number of workers, each signing
+// at PerSign rate, e.g. to choose BatchParams.Workers fitting a maintenance window
+func (e BatchEstimate) WithWorkers(workers int) time.Duration {
+	if workers < 1 {
+		workers = 1
+	}
+	rounds := (e.Invoices + workers - 1) / workers
+	return time.Duration(rounds) * e.PerSign
+}
+
+// EstimateBatch counts invoices of files, including every Invoice of multi-invoice files, and estimates
+// wall time of signing them given duration of a single signature, see CalibrateSign. Files are only parsed,
+// so the estimate excludes time of writing the results
+func EstimateBatch(files []string, perSignEstimate time.Duration) BatchEstimate {
+	estimate := BatchEstimate{Files: len(files), PerSign: perSignEstimate}
+	for _, file := range files {
+		doc, _, err := readDocument(file)
+		if err != nil {
+			estimate.Unreadable++
+			continue
+		}
+		estimate.Invoices += len(doc.FindElements("//Invoice"))
+	}
+	estimate.Duration = time.Duration(estimate.Invoices) * perSignEstimate
+	return estimate
+}
+
+// CalibrateSign measures duration of a single signature of signer by signing the fixed test invoice of SelfTest,
+// for EstimateBatch. The first signature of a token is often slower, so it's made and discarded before measuring
+func CalibrateSign(signer Signer) (time.Duration, error) {
+	digest := DigestForIIC(selfTestParams)
+	if _, err := signer.SignPKCS1v15(digest); err != nil {
+		return 0, signerError(err)
+	}
+	started := time.Now()
+	if _, err := signer.SignPKCS1v15(digest); err != nil {
+		return 0, signerError(err)
+	}
+	return time.Since(started), nil
+}
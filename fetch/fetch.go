@@ -0,0 +1,92 @@
+// Package fetch signs invoices referenced by HTTP(S) URL.
+// It's kept apart from package iic, so the core doesn't depend on HTTP
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/noshto/iic"
+)
+
+// DefaultMaxSize limits size of a fetched invoice when Options.MaxSize is zero
+const DefaultMaxSize = 10 << 20
+
+// Options adjusts fetching of invoices. HTTPClient defaults to http.DefaultClient, which verifies TLS
+// certificates; supply a custom one e.g. for client certificates or a private CA
+type Options struct {
+	HTTPClient *http.Client
+	MaxSize    int64
+}
+
+// httpClient returns client used for requests
+func (opts Options) httpClient() *http.Client {
+	if opts.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return opts.HTTPClient
+}
+
+// maxSize returns size limit of a fetched invoice
+func (opts Options) maxSize() int64 {
+	if opts.MaxSize <= 0 {
+		return DefaultMaxSize
+	}
+	return opts.MaxSize
+}
+
+// WriteIICFromURL fetches XML invoice from url, generates IIC for it with signer and saves the result to outFile
+func WriteIICFromURL(ctx context.Context, signer iic.Signer, url string, outFile string) error {
+	return WriteIICFromURLWithOptions(ctx, signer, url, outFile, Options{})
+}
+
+// WriteIICFromURLWithOptions is the same as WriteIICFromURL, but fetches the invoice according to opts
+func WriteIICFromURLWithOptions(ctx context.Context, signer iic.Signer, url string, outFile string, opts Options) error {
+	buf, err := Fetch(ctx, url, opts)
+	if err != nil {
+		return err
+	}
+	return iic.WriteIICFrom(bytes.NewReader(buf), &iic.Params{Signer: signer, OutFile: outFile})
+}
+
+// Fetch downloads XML document from url. Fails if the response isn't successful, exceeds opts.MaxSize
+// or doesn't look like XML. Cancellation and deadline of ctx are respected
+func Fetch(ctx context.Context, url string, opts Options) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/xml, text/xml")
+
+	resp, err := opts.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %s", url, resp.Status)
+	}
+
+	limit := opts.maxSize()
+	buf, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buf)) > limit {
+		return nil, fmt.Errorf("%s: invoice exceeds %d bytes", url, limit)
+	}
+	if !looksLikeXML(buf) {
+		return nil, fmt.Errorf("%s: response doesn't look like XML", url)
+	}
+	return buf, nil
+}
+
+// looksLikeXML reports whether buf starts with markup after optional byte order mark and whitespace
+func looksLikeXML(buf []byte) bool {
+	buf = bytes.TrimLeft(bytes.TrimPrefix(buf, []byte{0xEF, 0xBB, 0xBF}), " \t\r\n")
+	return len(buf) > 0 && buf[0] == '<'
+}
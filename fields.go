@@ -0,0 +1,83 @@
+package iic
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// InvoiceFields represents values the IIC is generated from
+type InvoiceFields struct {
+	TIN           string
+	IssueDateTime string
+	InvOrdNum     string
+	BusinUnitCode string
+	TCRCode       string
+	SoftCode      string
+	TotPrice      string
+}
+
+// NewInvoiceFields creates InvoiceFields from typed values, formatting them canonically: issued in the form of
+// CanonicalizeDateTime, keeping its timezone, and total rounded half-up to two decimals, see RoundPrice.
+// Nil total is an error
+func NewInvoiceFields(tin string, issued time.Time, ordinal int, businUnitCode string, tcrCode string, softCode string, total *big.Rat) (InvoiceFields, error) {
+	if total == nil {
+		return InvoiceFields{}, fmt.Errorf("TotPrice is nil")
+	}
+	return InvoiceFields{
+		TIN:           tin,
+		IssueDateTime: issued.Truncate(time.Second).Format(canonicalDateTimeLayout),
+		InvOrdNum:     strconv.Itoa(ordinal),
+		BusinUnitCode: businUnitCode,
+		TCRCode:       tcrCode,
+		SoftCode:      softCode,
+		TotPrice:      roundRat(total),
+	}, nil
+}
+
+// FieldsOf creates InvoiceFields from values in the order of GenerateIIC parameters
+func FieldsOf(params [7]string) InvoiceFields {
+	return InvoiceFields{
+		TIN:           params[0],
+		IssueDateTime: params[1],
+		InvOrdNum:     params[2],
+		BusinUnitCode: params[3],
+		TCRCode:       params[4],
+		SoftCode:      params[5],
+		TotPrice:      params[6],
+	}
+}
+
+// Array returns values in the order of GenerateIIC parameters
+func (f InvoiceFields) Array() [7]string {
+	return [7]string{f.TIN, f.IssueDateTime, f.InvOrdNum, f.BusinUnitCode, f.TCRCode, f.SoftCode, f.TotPrice}
+}
+
+// Validate checks format of every value, see ValidateFields
+func (f InvoiceFields) Validate(opts ValidateOptions) error {
+	return ValidateFields(f.Array(), opts)
+}
+
+// ExtractFields returns values of the IIC of the first Invoice of doc by names of FieldNames, e.g. for
+// inspection with DiffFields or PlainIIC. Missing values are left out of the map and reported all at once
+// as *ValidationError
+func ExtractFields(doc *etree.Document) (map[string]string, error) {
+	invoice := doc.FindElement("//Invoice")
+	if invoice == nil {
+		return nil, validationError([]error{fmt.Errorf("can't find element %s", "//Invoice")})
+	}
+	params, fieldErrs := lookupFields(doc, invoice, ParseOptions{})
+	fields := map[string]string{}
+	errs := []error{}
+	for i, err := range fieldErrs {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fields[FieldNames[i]] = params[i]
+	}
+	return fields, validationError(errs)
+}
@@ -0,0 +1,31 @@
+package iic
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestNewInvoiceFieldsCanonical(t *testing.T) {
+	issued := time.Date(2019, 6, 12, 15, 5, 43, 500000000, time.UTC)
+	fields, err := NewInvoiceFields("12345678", issued, 9952, "bb123bb123", "cc123cc123", "ss123ss123", big.NewRat(9901, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, err := CanonicalizeDateTime("2019-06-12T15:05:43Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields.IssueDateTime != canonical {
+		t.Errorf("IssueDateTime = %s, want canonical %s", fields.IssueDateTime, canonical)
+	}
+	if fields.TotPrice != "99.01" {
+		t.Errorf("TotPrice = %s, want 99.01", fields.TotPrice)
+	}
+}
+
+func TestNewInvoiceFieldsNilTotal(t *testing.T) {
+	if _, err := NewInvoiceFields("12345678", time.Now(), 1, "bb123bb123", "cc123cc123", "ss123ss123", nil); err == nil {
+		t.Error("nil total is accepted")
+	}
+}
@@ -0,0 +1,252 @@
+package iic
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/beevik/etree"
+)
+
+// writeFileAtomic writes data into a temporary file next to path and renames it to path,
+// so readers never see a partially written file. If sync is set, the file and its directory are
+// flushed to disk as well, so a power loss never leaves a partial file at path. New file gets
+// the default mode subject to umask, existing one keeps its mode
+func writeFileAtomic(path string, data []byte, sync bool) error {
+	return writeFileVia(path, data, sync, os.Rename)
+}
+
+// writeFileExclusive is the same as writeFileAtomic, but fails with os.ErrExist if path already exists.
+// The temporary file is hard linked to path, or, where hard links aren't supported, renamed over
+// an empty file created exclusively at path
+func writeFileExclusive(path string, data []byte, sync bool) error {
+	err := writeFileVia(path, data, sync, linkExclusive)
+	if os.IsExist(err) {
+		return fmt.Errorf("%w: %s", os.ErrExist, path)
+	}
+	return err
+}
+
+// linkExclusive moves tmp to path, failing if path already exists
+func linkExclusive(tmp string, path string) error {
+	err := os.Link(tmp, path)
+	if err == nil || !linkUnsupported(err) {
+		return err
+	}
+	placeholder, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	placeholder.Close()
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// linkUnsupported reports whether err of os.Link means the file system doesn't support hard links
+func linkUnsupported(err error) bool {
+	return errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.ENOSYS) ||
+		errors.Is(err, syscall.EOPNOTSUPP)
+}
+
+// writeFileVia writes data into a temporary file next to path and moves it to path with move
+func writeFileVia(path string, data []byte, sync bool, move func(string, string) error) error {
+	tmp, err := createTemp(filepath.Dir(path), "."+filepath.Base(path)+".", ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if sync {
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := move(tmp.Name(), path); err != nil {
+		return err
+	}
+	if sync {
+		syncDir(filepath.Dir(path))
+	}
+	return nil
+}
+
+// createTemp creates a new file in dir named prefix, a random number and suffix. Unlike ioutil.TempFile,
+// the file is created with the default mode subject to umask, so it's readable like other files written
+func createTemp(dir string, prefix string, suffix string) (*os.File, error) {
+	for try := 0; ; try++ {
+		random := make([]byte, 8)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		name := filepath.Join(dir, prefix+hex.EncodeToString(random)+suffix)
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) && try < 10000 {
+			continue
+		}
+		return f, err
+	}
+}
+
+// syncDir flushes directory entries of dir to disk, so a rename survives a power loss. It's best effort,
+// since some platforms, e.g. Windows, can't sync directories
+func syncDir(dir string) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	f.Sync()
+	f.Close()
+}
+
+// utf8BOM is the byte order mark some Windows editors put at the beginning of UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// readDocument loads XML document from file, stripping leading byte order mark.
+// Returns whether the file had one
+func readDocument(file string) (*etree.Document, bool, error) {
+	return readDocumentWith(file, nil)
+}
+
+// readDocumentWith is the same as readDocument, but reads with given settings, see parseDocument
+func readDocumentWith(file string, settings *etree.ReadSettings) (*etree.Document, bool, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, false, err
+	}
+	return parseDocument(buf, settings)
+}
+
+// parseDocument is the same as readDocument, but parses XML document from buf with given settings.
+// Nil settings are the strict default ones. Nil CharsetReader of settings reads input as is, like the default.
+// Unless settings are Permissive, the document must have end tags matching its start tags, which etree
+// doesn't check
+func parseDocument(buf []byte, settings *etree.ReadSettings) (*etree.Document, bool, error) {
+	hasBOM := bytes.HasPrefix(buf, utf8BOM)
+	doc := etree.NewDocument()
+	if settings != nil {
+		charsetReader := doc.ReadSettings.CharsetReader
+		doc.ReadSettings = *settings
+		if doc.ReadSettings.CharsetReader == nil {
+			doc.ReadSettings.CharsetReader = charsetReader
+		}
+	}
+	if err := doc.ReadFromBytes(bytes.TrimPrefix(buf, utf8BOM)); err != nil {
+		return nil, false, err
+	}
+	if !doc.ReadSettings.Permissive {
+		if err := matchEndTags(bytes.TrimPrefix(buf, utf8BOM), doc.ReadSettings); err != nil {
+			return nil, false, err
+		}
+	}
+	return doc, hasBOM, nil
+}
+
+// matchEndTags checks that every start tag of XML document buf, already read with settings, is closed by
+// a matching end tag
+func matchEndTags(buf []byte, settings etree.ReadSettings) error {
+	decoder := xml.NewDecoder(bytes.NewReader(buf))
+	decoder.CharsetReader = settings.CharsetReader
+	decoder.Entity = settings.Entity
+	open := openElements{}
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			return open.end()
+		}
+		if err != nil {
+			return err
+		}
+		if err := open.track(token); err != nil {
+			return err
+		}
+	}
+}
+
+// openElements tracks names of elements whose start tag is read until their end tag is
+type openElements []xml.Name
+
+// track records start tag of token or fails if end tag of token doesn't match the innermost open element
+func (open *openElements) track(token xml.Token) error {
+	switch t := token.(type) {
+	case xml.StartElement:
+		*open = append(*open, t.Name)
+	case xml.EndElement:
+		if len(*open) == 0 {
+			return fmt.Errorf("XML syntax error: unexpected end element </%s>", qualifiedName(t.Name))
+		}
+		if name := (*open)[len(*open)-1]; name != t.Name {
+			return fmt.Errorf("XML syntax error: element <%s> closed by </%s>", qualifiedName(name), qualifiedName(t.Name))
+		}
+		*open = (*open)[:len(*open)-1]
+	}
+	return nil
+}
+
+// end fails if an element is left open at the end of the document
+func (open openElements) end() error {
+	if len(open) > 0 {
+		return fmt.Errorf("XML syntax error: unexpected EOF, element <%s> isn't closed", qualifiedName(open[len(open)-1]))
+	}
+	return nil
+}
+
+// writeDocument atomically saves doc into file in params.OutputEncoding, prefixed with byte order mark if
+// the input had one, params.PreserveFormatting is set and the output is UTF-8. If params.Reproducible is set,
+// line endings are LF and byte order mark is never written. Existing file is replaced
+// unless params.NoOverwrite is set. The file is flushed to disk unless params.NoSync is set
+func writeDocument(doc *etree.Document, file string, hasBOM bool, params *Params) error {
+	if params.OutputEncoding != EncodingUTF8 {
+		declareEncoding(doc, params.OutputEncoding)
+	}
+	if params.Reproducible {
+		doc.WriteSettings.UseCRLF = false
+	}
+	buf, err := doc.WriteToBytes()
+	if err != nil {
+		return err
+	}
+	if params.Reproducible {
+		buf = normalizeLineEndings(buf)
+	}
+	if buf, err = encode(buf, params.OutputEncoding); err != nil {
+		return documentError(err)
+	}
+	if hasBOM && params.PreserveFormatting && !params.Reproducible && params.OutputEncoding == EncodingUTF8 {
+		buf = append(append([]byte{}, utf8BOM...), buf...)
+	}
+	if params.NoOverwrite {
+		return writeFileExclusive(file, buf, !params.NoSync)
+	}
+	return writeFileAtomic(file, buf, !params.NoSync)
+}
+
+// normalizeLineEndings replaces CRLF and CR line endings of buf, e.g. of comments or text kept from InFile, with LF
+func normalizeLineEndings(buf []byte) []byte {
+	buf = bytes.Replace(buf, []byte("\r\n"), []byte("\n"), -1)
+	return bytes.Replace(buf, []byte("\r"), []byte("\n"), -1)
+}
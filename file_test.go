@@ -0,0 +1,216 @@
+package iic
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// dirNames returns sorted names of files in dir
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestWriteFileAtomicInterrupted(t *testing.T) {
+	for _, sync := range []bool{true, false} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.xml")
+		if err := ioutil.WriteFile(path, []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// a temporary file left by a write interrupted before the rename
+		leftover := filepath.Join(dir, ".out.xml.1234.tmp")
+		if err := ioutil.WriteFile(leftover, []byte("<partial"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// rename onto a non-empty directory fails after the temporary file is written
+		blocked := filepath.Join(dir, "blocked")
+		if err := os.MkdirAll(filepath.Join(blocked, "child"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeFileAtomic(blocked, []byte("new"), sync); err == nil {
+			t.Fatal("write onto a directory succeeded")
+		}
+		if names := dirNames(t, dir); len(names) != 3 {
+			t.Errorf("sync %v: failed write left %v", sync, names)
+		}
+
+		if err := writeFileAtomic(path, []byte("new"), sync); err != nil {
+			t.Fatal(err)
+		}
+		if buf, _ := ioutil.ReadFile(path); string(buf) != "new" {
+			t.Errorf("sync %v: content is %q, want new", sync, buf)
+		}
+		if buf, _ := ioutil.ReadFile(leftover); string(buf) != "<partial" {
+			t.Errorf("sync %v: leftover is %q", sync, buf)
+		}
+		if names := dirNames(t, dir); len(names) != 3 {
+			t.Errorf("sync %v: write left %v", sync, names)
+		}
+	}
+}
+
+func TestWriteIICNoSync(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	in := writeTestFile(t, "in.xml", testInvoice)
+	dir := t.TempDir()
+	params := &Params{Signer: signer, InFile: in, OutFile: filepath.Join(dir, "out.xml"), Sidecar: true, NoSync: true}
+	if err := WriteIIC(params); err != nil {
+		t.Fatal(err)
+	}
+	if names := dirNames(t, dir); len(names) != 2 || names[0] != "out.iic.json" || names[1] != "out.xml" {
+		t.Errorf("output directory has %v, want out.iic.json and out.xml", names)
+	}
+	doc, _, err := readDocument(params.OutFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyTestDocument(t, signer, doc); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteFileAtomicMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported")
+	}
+	dir := t.TempDir()
+	created := filepath.Join(dir, "created.xml")
+	if err := writeFileAtomic(created, []byte("new"), false); err != nil {
+		t.Fatal(err)
+	}
+	// the default mode subject to umask, like of a file created by os.Create
+	reference := filepath.Join(dir, "reference.xml")
+	if err := ioutil.WriteFile(reference, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if mode, want := fileMode(t, created), fileMode(t, reference); mode != want {
+		t.Errorf("new file has mode %v, want %v", mode, want)
+	}
+
+	existing := filepath.Join(dir, "existing.xml")
+	if err := ioutil.WriteFile(existing, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(existing, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(existing, []byte("new"), false); err != nil {
+		t.Fatal(err)
+	}
+	if mode := fileMode(t, existing); mode != 0640 {
+		t.Errorf("replaced file has mode %v, want %v", mode, os.FileMode(0640))
+	}
+}
+
+// fileMode returns permission bits of file
+func fileMode(t *testing.T, file string) os.FileMode {
+	t.Helper()
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Mode().Perm()
+}
+
+func TestWriteFileExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.xml")
+	if err := writeFileExclusive(path, []byte("first"), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileExclusive(path, []byte("second"), false); !errors.Is(err, os.ErrExist) {
+		t.Errorf("second write returned %v, want os.ErrExist", err)
+	}
+	if buf, _ := ioutil.ReadFile(path); string(buf) != "first" {
+		t.Errorf("content is %q, want first", buf)
+	}
+}
+
+func TestWriteIICBOM(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	in := writeTestFile(t, "in.xml", string(utf8BOM)+testInvoice)
+	for _, preserve := range []bool{false, true} {
+		out := filepath.Join(t.TempDir(), "out.xml")
+		if err := WriteIIC(&Params{Signer: signer, InFile: in, OutFile: out, PreserveFormatting: preserve}); err != nil {
+			t.Fatal(err)
+		}
+		buf, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hasBOM := bytes.HasPrefix(buf, utf8BOM); hasBOM != preserve {
+			t.Errorf("PreserveFormatting %v: output has BOM %v", preserve, hasBOM)
+		}
+		doc, _, err := readDocument(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := verifyTestDocument(t, signer, doc); err != nil {
+			t.Errorf("PreserveFormatting %v: %v", preserve, err)
+		}
+	}
+}
+
+func TestWriteIICReproducible(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	// the same invoice as saved by a Windows editor: CRLF line endings and byte order mark
+	windows := string(utf8BOM) + strings.Replace(strings.Replace(testInvoice, "\n", "\r\n", -1), "<Header", "<!-- exported\r\nby Excel --><Header", 1)
+	unix := strings.Replace(testInvoice, "<Header", "<!-- exported\nby Excel --><Header", 1)
+
+	outputs := [][]byte{}
+	IICs := []string{}
+	for _, content := range []string{windows, unix} {
+		for _, reproducible := range []bool{true, false} {
+			out := filepath.Join(t.TempDir(), "out.xml")
+			params := &Params{Signer: signer, InFile: writeTestFile(t, "in.xml", content), OutFile: out,
+				PreserveFormatting: true, Reproducible: reproducible}
+			if err := WriteIIC(params); err != nil {
+				t.Fatal(err)
+			}
+			doc, _, err := readDocument(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			IIC, _, err := ReadIIC(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			IICs = append(IICs, IIC)
+			if reproducible {
+				buf, err := ioutil.ReadFile(out)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if bytes.HasPrefix(buf, utf8BOM) || bytes.Contains(buf, []byte("\r")) {
+					t.Errorf("reproducible output has BOM or CR: %q", buf)
+				}
+				outputs = append(outputs, buf)
+			}
+		}
+	}
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Errorf("reproducible outputs of Windows and Unix input differ:\n%s\n%s", outputs[0], outputs[1])
+	}
+	for _, IIC := range IICs[1:] {
+		if IIC != IICs[0] {
+			t.Errorf("IICs differ: %v", IICs)
+		}
+	}
+}
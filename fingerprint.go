@@ -0,0 +1,41 @@
+package iic
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// DocumentFingerprint returns hex SHA-256 of canonical XML of the whole doc, see Canonicalize, e.g. for
+// deduplication and integrity indexing of signed files. Unlike the IIC, which covers seven values only, it
+// changes with any content of the document. Whitespace-only text between elements is ignored, so
+// the fingerprint doesn't depend on indentation
+func DocumentFingerprint(doc *etree.Document) (string, error) {
+	stripped := doc.Copy()
+	stripWhitespace(&stripped.Element)
+	canonical, err := Canonicalize(stripped)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(canonical)), nil
+}
+
+// stripWhitespace removes whitespace-only text from elem and its descendants which have child elements,
+// i.e. indentation, keeping text of leaf elements
+func stripWhitespace(elem *etree.Element) {
+	if len(elem.ChildElements()) == 0 {
+		return
+	}
+	for _, token := range append([]etree.Token{}, elem.Child...) {
+		switch token := token.(type) {
+		case *etree.CharData:
+			if len(strings.TrimSpace(token.Data)) == 0 {
+				elem.RemoveChild(token)
+			}
+		case *etree.Element:
+			stripWhitespace(token)
+		}
+	}
+}
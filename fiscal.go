@@ -0,0 +1,36 @@
+package iic
+
+import "github.com/beevik/etree"
+
+// FiscalResult represents everything a point of sale needs to submit and print an invoice: values and IIC
+// of the invoice, Payload to upload, i.e. the document with IIC, and VerificationURL for the receipt QR code.
+// QR is a PNG image of the QR code, filled in only by qr.FiscalizeInvoice
+type FiscalResult struct {
+	Fields          [7]string
+	IIC             string
+	IICSignature    string
+	Payload         []byte
+	VerificationURL string
+	QR              []byte
+}
+
+// FiscalizeInvoice generates IIC for the first Invoice of doc, writes it into doc and returns the result
+// without QR, so no QR encoder is needed. Use qr.FiscalizeInvoice to render the QR code as well
+func FiscalizeInvoice(signer Signer, doc *etree.Document) (*FiscalResult, error) {
+	params := &Params{Signer: signer}
+	parsed, IIC, IICSignature, err := signDocument(signer, doc, params)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := doc.WriteToBytes()
+	if err != nil {
+		return nil, err
+	}
+	return &FiscalResult{
+		Fields:          parsed,
+		IIC:             IIC,
+		IICSignature:    IICSignature,
+		Payload:         payload,
+		VerificationURL: VerificationURL(parsed, IIC),
+	}, nil
+}
@@ -0,0 +1,58 @@
+package iic
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// generatedAtName names the attribute or element written by Params.GeneratedAt
+const generatedAtName = "IICGeneratedAt"
+
+// setGeneratedAt writes at in RFC 3339 as IICGeneratedAt of the invoice, replacing existing one. It's written
+// as a child element with namespace prefix of the invoice if placement is IICElements, otherwise as an attribute
+func setGeneratedAt(invoice *etree.Element, at time.Time, placement IICPlacement) {
+	for _, child := range invoice.SelectElements(generatedAtName) {
+		invoice.RemoveChild(child)
+	}
+	invoice.RemoveAttr(generatedAtName)
+	if placement == IICElements {
+		invoice.CreateElement(qualified(invoice, generatedAtName)).SetText(at.Format(time.RFC3339))
+		return
+	}
+	invoice.CreateAttr(generatedAtName, at.Format(time.RFC3339))
+}
+
+// setGeneratedAts writes at as IICGeneratedAt of invoices of doc which were signed
+func setGeneratedAts(doc *etree.Document, results []InvoiceResult, at time.Time, placement IICPlacement) {
+	invoices := doc.FindElements("//Invoice")
+	for _, result := range results {
+		if result.Status == InvoiceSigned {
+			setGeneratedAt(invoices[result.Index], at, placement)
+		}
+	}
+}
+
+// ReadGeneratedAt returns time IIC of the first Invoice of doc was generated at, written by Params.GeneratedAt
+func ReadGeneratedAt(doc *etree.Document) (time.Time, error) {
+	invoice := doc.FindElement("//Invoice")
+	if invoice == nil {
+		return time.Time{}, documentError(fmt.Errorf("can't find element %s", "//Invoice"))
+	}
+	var value string
+	var err error
+	if invoice.SelectElement(generatedAtName) != nil {
+		value, err = textOf(invoice, generatedAtName)
+	} else {
+		value, err = localAttributeOf(invoice, generatedAtName)
+	}
+	if err != nil {
+		return time.Time{}, documentError(err)
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, documentError(err)
+	}
+	return at, nil
+}
@@ -4,5 +4,9 @@ go 1.15
 
 require (
 	github.com/beevik/etree v1.1.0
+	github.com/miekg/pkcs11 v1.0.3
 	github.com/noshto/dsig v0.0.12
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
+	golang.org/x/time v0.3.0
 )
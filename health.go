@@ -0,0 +1,82 @@
+package iic
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+
+	"github.com/miekg/pkcs11"
+)
+
+// minSignatureLength is the length of a signature made with the weakest acceptable, 1024 bit, RSA key
+const minSignatureLength = 128
+
+// healthCheckDigest is a fixed sha256 digest signed by HealthCheck
+var healthCheckDigest = make([]byte, crypto.SHA256.Size())
+
+// tokenAbsentCodes lists PKCS#11 return values meaning that the token isn't available
+var tokenAbsentCodes = []pkcs11.Error{
+	pkcs11.CKR_TOKEN_NOT_PRESENT,
+	pkcs11.CKR_TOKEN_NOT_RECOGNIZED,
+	pkcs11.CKR_DEVICE_REMOVED,
+	pkcs11.CKR_SESSION_CLOSED,
+	pkcs11.CKR_SESSION_HANDLE_INVALID,
+}
+
+// HealthCheck performs lightweight check that signer is usable, e.g. for readiness probes: signs a fixed
+// digest and checks that the signature length is plausible. Returns ErrTokenAbsent if the token isn't
+// available and ErrKeyUnusable if the token is present but signing fails
+func HealthCheck(signer Signer) error {
+	signature, err := signer.SignPKCS1v15(healthCheckDigest)
+	if err != nil {
+		if isTokenAbsent(err) {
+			return signerError(fmt.Errorf("%w: %v", ErrTokenAbsent, err))
+		}
+		return signerError(fmt.Errorf("%w: %v", ErrKeyUnusable, err))
+	}
+
+	if isECDSA(signer) {
+		if len(signature) == 0 {
+			return signerError(fmt.Errorf("%w: signature is empty", ErrKeyUnusable))
+		}
+		return nil
+	}
+	expected := minSignatureLength
+	if cert, err := certificateOf(signer); err == nil {
+		if pub, ok := cert.PublicKey.(*rsa.PublicKey); ok {
+			expected = pub.Size()
+		}
+	}
+	if len(signature) < minSignatureLength || len(signature) < expected {
+		return signerError(fmt.Errorf("%w: signature is %d bytes long, expected %d", ErrKeyUnusable, len(signature), expected))
+	}
+	return nil
+}
+
+// isECDSA checks whether signer creates ECDSA signatures, whose length doesn't depend on RSA key size
+func isECDSA(signer Signer) bool {
+	if s, ok := signer.(interface{ Algorithm() Algorithm }); ok {
+		return s.Algorithm() == AlgorithmECDSA
+	}
+	if cert, err := certificateOf(signer); err == nil {
+		_, ok := cert.PublicKey.(*ecdsa.PublicKey)
+		return ok
+	}
+	return false
+}
+
+// isTokenAbsent checks whether err is a PKCS#11 error meaning that the token isn't available
+func isTokenAbsent(err error) bool {
+	var code pkcs11.Error
+	if !errors.As(err, &code) {
+		return false
+	}
+	for _, absent := range tokenAbsentCodes {
+		if code == absent {
+			return true
+		}
+	}
+	return false
+}
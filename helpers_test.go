@@ -0,0 +1,120 @@
+package iic
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// testInvoice is a CASH invoice of seller 12345678 with every value of the IIC
+const testInvoice = `<?xml version="1.0" encoding="UTF-8"?>
+<RegisterInvoiceRequest xmlns="https://efi.tax.gov.me/fs/schema" Id="Request">
+  <Header SendDateTime="2019-06-12T17:05:43+02:00" UUID="x"/>
+  <Invoice TypeOfInv="CASH" IssueDateTime="2019-06-12T17:05:43+02:00" InvOrdNum="9952" BusinUnitCode="bb123bb123" TCRCode="cc123cc123" SoftCode="ss123ss123" TotPrice="99.01" OperatorCode="oo123oo123">
+    <PayMethods><PayMethod Type="BANKNOTE" Amt="99.01"/></PayMethods>
+    <Seller IDType="TIN" IDNum="12345678" Name="A &amp; B"/>
+  </Invoice>
+</RegisterInvoiceRequest>
+`
+
+// testInvoiceFields are values of the IIC of testInvoice
+var testInvoiceFields = [7]string{"12345678", "2019-06-12T17:05:43+02:00", "9952", "bb123bb123", "cc123cc123", "ss123ss123", "99.01"}
+
+// testBundle returns a document of invoices of seller 12345678 with given ordinals, in that order
+func testBundle(ordinals ...string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n<Invoices>\n")
+	for _, ordinal := range ordinals {
+		b.WriteString(`  <Invoice IssueDateTime="2019-06-12T17:05:43+02:00" InvOrdNum="` + ordinal + `" BusinUnitCode="bb123bb123" TCRCode="cc123cc123" SoftCode="ss123ss123" TotPrice="10.00">` + "\n")
+		b.WriteString(`    <Seller IDType="TIN" IDNum="12345678"/>` + "\n  </Invoice>\n")
+	}
+	b.WriteString("</Invoices>\n")
+	return b.String()
+}
+
+var testKey struct {
+	once sync.Once
+	key  *rsa.PrivateKey
+	err  error
+}
+
+// newTestSigner returns a software signer with a self-signed certificate of seller 12345678 and the certificate
+// in PEM. The RSA key is shared by all tests, as generating it is slow
+func newTestSigner(t testing.TB) (*CertifiedSigner, []byte) {
+	t.Helper()
+	testKey.once.Do(func() {
+		testKey.key, testKey.err = rsa.GenerateKey(rand.Reader, 2048)
+	})
+	if testKey.err != nil {
+		t.Fatal(testKey.err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test", SerialNumber: "12345678"},
+		NotBefore:    time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &testKey.key.PublicKey, testKey.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewKeySigner(testKey.key, AlgorithmRSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewCertifiedSigner(key, cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// writeTestFile writes content into file name of a temporary directory of the test and returns its path
+func writeTestFile(t testing.TB, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readTestDocument parses content as XML document
+func readTestDocument(t testing.TB, content string) *etree.Document {
+	t.Helper()
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(content); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// verifyTestDocument verifies IIC of the first Invoice of doc against the certificate of signer
+func verifyTestDocument(t testing.TB, signer *CertifiedSigner, doc *etree.Document) error {
+	t.Helper()
+	fields, err := parse(doc, ParseOptions{})
+	if err != nil {
+		return err
+	}
+	IIC, IICSignature, err := ReadIIC(doc)
+	if err != nil {
+		return err
+	}
+	return VerifyIIC(signer.cert, fields, IIC, IICSignature)
+}
@@ -3,65 +3,341 @@ package iic
 import (
 	"crypto"
 	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/beevik/etree"
 	"github.com/noshto/dsig/pkg/safenet"
 )
 
-// Params represents collection of parameters needed for IIC function
+// Params represents collection of parameters needed for IIC function.
+// Signer is used instead of initializing SafeNet with SafenetConfig when set.
+// Registry, when set, selects signer by Seller TIN of the invoice and takes precedence over Signer.
+// ReadSettings, when set, replace strict settings of the XML reader of InFile, e.g. Permissive for documents with
+// common mistakes or Entity for custom entities. Values wrapped in CDATA are read as text regardless of them.
+// Validate enables checking format of values with ValidateFields, ValidateSellerID and ValidateOptions before IIC is generated.
+// Severity downgrades findings of validators to warnings or upgrades warnings to errors, keyed by validator id,
+// e.g. ValidatorTCRCode, or by name of WarningCode, e.g. "swapped".
+// GuardTotal rejects empty or zero TotPrice, which is usually a total not computed yet, AllowZeroTotal accepts zero.
+// The single-invoice API fails with ErrMultipleInvoices on documents with several Invoice elements, use WriteIICAll
+// to sign them all. SignFirstInvoice restores signing only the first one with a warning, for compatibility.
+// SeenStore, when set, refuses to sign an ordinal it has already seen with ErrReplayed, see ReplayKey.
+// Ordinals are recorded once OutFile is written. ReservingSeenStore also reserves them once their IIC is generated,
+// a failed write releases them. ForceReplay signs seen ordinals anyway.
+// SkipValid makes WriteIICAll leave invoices which already have IIC valid for the signer's certificate untouched.
+// AllOrNothing makes WriteIICAll write OutFile only if every invoice could be signed, see Stage.
+// ExpectedDate, when set, flags invoices whose IssueDateTime is on another date, e.g. in a nightly batch of a business day.
+// They're warned about, or rejected if ExpectedDateStrict is set.
+// InitTimeout limits SafeNet initialization, zero means no limit.
+// PINFunc, when set, supplies PIN for SafeNet initialization instead of UnlockPin of SafenetConfig.
+// SoftCode, when set, replaces SoftCode of the document before IIC is computed.
+// Overrides fill in missing Invoice attributes before IIC is computed, ForceOverrides replaces existing ones too.
+// CanonicalDateTime rewrites IssueDateTime of the document in canonical form before IIC is computed, see CanonicalizeDateTime.
+// NormalizeTotal strips currency and thousands separators from the total of the document and rounds it to two decimals
+// before IIC is computed, see NormalizePrice.
+// Sidecar enables writing IIC details into a JSON file next to OutFile, see SidecarPath.
+// Vendor names the software and its version in the sidecar and StoredInvoice, it doesn't affect the IIC.
+// IICPlacement defines whether IIC and IICSignature are written as attributes of the Invoice or its child elements.
+// OutputStyle defines indentation of OutFile, tabs by default. OutputEncoding defines its encoding, UTF-8 by default.
+// SchemaVersion, when set, requires documents to declare this schema version, see DetectSchemaVersion.
+// RemoveSignature removes existing XML-DSIG signature of InFile, otherwise ErrSignaturePresent is returned.
+// GeneratedAt writes time IIC was generated by Clock, system time if it's nil, into IICGeneratedAt of the Invoice
+// according to IICPlacement, see ReadGeneratedAt. It doesn't affect the IIC.
+// PlainComment inserts the plain IIC string as an XML comment above the Invoice for debugging, see StripPlainComments.
+// PreserveFormatting keeps byte order mark of InFile in OutFile, otherwise OutFile is written without it.
+// Reproducible writes OutFile with LF line endings and without byte order mark on every platform, so its bytes
+// can be hashed or diffed downstream. It doesn't affect the IIC.
+// OutFile is written atomically and replaced if it exists, NoOverwrite makes writing fail with os.ErrExist instead.
+// OutFile is flushed to disk before it's renamed into place, NoSync skips it for throughput of huge batches.
+// Environment selects the fiscalization environment, Production by default. SandboxSigner, e.g. a software test key,
+// replaces SafeNet when it can't be initialized, only in Sandbox. Setting it in Production is an error.
+// Logger receives warnings, they are discarded when it's nil. Warnings are reported in results of WriteIICAll
+// and WriteIICBatch as well
 type Params struct {
-	SafenetConfig *safenet.Config
-	InFile        string
-	OutFile       string
+	SafenetConfig      *safenet.Config
+	Signer             Signer
+	Registry           *CertRegistry
+	InitTimeout        time.Duration
+	PINFunc            PINFunc
+	InFile             string
+	OutFile            string
+	ReadSettings       *etree.ReadSettings
+	ParseOptions       ParseOptions
+	SoftCode           string
+	Overrides          Overrides
+	ForceOverrides     bool
+	CanonicalDateTime  bool
+	NormalizeTotal     bool
+	Validate           bool
+	ValidateOptions    ValidateOptions
+	Severity           map[string]Severity
+	ExpectedDate       time.Time
+	ExpectedDateStrict bool
+	GuardTotal         bool
+	AllowZeroTotal     bool
+	SignFirstInvoice   bool
+	SeenStore          SeenStore
+	ForceReplay        bool
+	SkipValid          bool
+	AllOrNothing       bool
+	Sidecar            bool
+	Vendor             VendorInfo
+	IICPlacement       IICPlacement
+	OutputStyle        OutputStyle
+	OutputEncoding     OutputEncoding
+	SchemaVersion      string
+	RemoveSignature    bool
+	GeneratedAt        bool
+	Clock              Clock
+	PlainComment       bool
+	PreserveFormatting bool
+	Reproducible       bool
+	NoOverwrite        bool
+	NoSync             bool
+	Environment        Environment
+	SandboxSigner      Signer
+	Logger             *log.Logger
+
+	warnings []Warning
+	timings  *Timings
+	reserved []reservation
 }
 
 // WriteIIC generates IIC from given parameters, writes it into the XML and saves to outFile
 func WriteIIC(params *Params) error {
-	// Load file
-	doc := etree.NewDocument()
-	if err := doc.ReadFromFile(params.InFile); err != nil {
+	if err := validateParams(params); err != nil {
 		return err
 	}
+	if len(params.InFile) == 0 {
+		return fmt.Errorf("params: InFile is empty")
+	}
+	if len(params.OutFile) == 0 {
+		return fmt.Errorf("params: OutFile is empty")
+	}
 
-	// Parse parameters
-	parsed, err := parse(doc)
+	return withSigner(params, func(signer Signer) error {
+		_, _, err := writeIIC(signer, params)
+		return err
+	})
+}
+
+// warnf records a warning about the whole document with given code, see warn
+func (params *Params) warnf(code WarningCode, format string, v ...interface{}) {
+	params.warn(Warning{Code: code, Message: fmt.Sprintf(format, v...)})
+}
+
+// warn records warning until it's taken with takeWarnings and prints it into params.Logger if it's set
+func (params *Params) warn(warning Warning) {
+	params.warnings = append(params.warnings, warning)
+	if params.Logger != nil {
+		params.Logger.Printf("warning: %s", warning)
+	}
+}
+
+// takeWarnings returns warnings recorded since the last call
+func (params *Params) takeWarnings() []Warning {
+	warnings := params.warnings
+	params.warnings = nil
+	return warnings
+}
+
+// validateParams checks that params are present and provide a way to obtain a signer
+func validateParams(params *Params) error {
+	if params == nil {
+		return fmt.Errorf("params: nil")
+	}
+	if params.Registry == nil && params.Signer == nil && params.SafenetConfig == nil {
+		return fmt.Errorf("params: neither Registry, Signer nor SafenetConfig is set")
+	}
+	if params.SandboxSigner != nil && params.Environment != Sandbox {
+		return fmt.Errorf("params: SandboxSigner is set in %s environment", params.Environment)
+	}
+	return nil
+}
+
+// WriteIICFrom is the same as WriteIIC, but reads the XML from r instead of params.InFile
+func WriteIICFrom(r io.Reader, params *Params) error {
+	if err := validateParams(params); err != nil {
+		return err
+	}
+	if len(params.OutFile) == 0 {
+		return fmt.Errorf("params: OutFile is empty")
+	}
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return documentError(err)
+	}
+	doc, hasBOM, err := parseDocument(buf, params.ReadSettings)
 	if err != nil {
+		return documentError(err)
+	}
+
+	return withSigner(params, func(signer Signer) error {
+		_, _, err := writeIICDocument(signer, doc, hasBOM, params)
 		return err
+	})
+}
+
+// writeIIC generates IIC for params.InFile using given signer and saves the result to params.OutFile
+func writeIIC(signer Signer, params *Params) (string, string, error) {
+	// Load file
+	started := time.Now()
+	doc, hasBOM, err := readDocumentWith(params.InFile, params.ReadSettings)
+	if err != nil {
+		return "", "", documentError(err)
 	}
+	params.record(parsePhase, started)
+	return writeIICDocument(signer, doc, hasBOM, params)
+}
 
+// writeIICDocument generates IIC for doc using given signer and saves the result to params.OutFile
+func writeIICDocument(signer Signer, doc *etree.Document, hasBOM bool, params *Params) (string, string, error) {
 	// Generate
-	IIC, IICSignature, err := GenerateIIC(params.SafenetConfig, parsed)
+	parsed, IIC, IICSignature, err := signDocument(signer, doc, params)
 	if err != nil {
-		return err
+		params.settleReplay(false)
+		return "", "", err
 	}
 
 	// Save
-	doc.FindElement("//Invoice").RemoveAttr("IIC")
-	doc.FindElement("//Invoice").CreateAttr("IIC", IIC)
+	started := time.Now()
+	formatDocument(doc, params.OutputStyle)
 
-	doc.FindElement("//Invoice").RemoveAttr("IICSignature")
-	doc.FindElement("//Invoice").CreateAttr("IICSignature", IICSignature)
+	err = writeDocument(doc, params.OutFile, hasBOM, params)
+	if err != nil {
+		params.settleReplay(false)
+		return "", "", err
+	}
+	if err := params.settleReplay(true); err != nil {
+		return "", "", err
+	}
 
-	doc.IndentTabs()
-	doc.Root().SetTail("")
+	if params.Sidecar {
+		if err := writeSidecar(doc, parsed, IIC, IICSignature, params); err != nil {
+			return "", "", err
+		}
+	}
+	params.record(writePhase, started)
+	return IIC, IICSignature, nil
+}
+
+// signDocument generates IIC for the first Invoice of doc and writes it into the Invoice.
+// Returns values the IIC was generated from
+func signDocument(signer Signer, doc *etree.Document, params *Params) ([7]string, string, string, error) {
+	started := time.Now()
+	if err := checkDocument(doc, params); err != nil {
+		return [7]string{}, "", "", documentError(err)
+	}
+	if err := checkSingleInvoice(doc, params); err != nil {
+		return [7]string{}, "", "", err
+	}
+
+	// Apply overrides
+	if err := applyOverrides(doc, params); err != nil {
+		return [7]string{}, "", "", documentError(err)
+	}
 
-	err = doc.WriteToFile(params.OutFile)
+	// Parse parameters
+	parsed, err := parse(doc, params.ParseOptions)
 	if err != nil {
-		return err
+		return [7]string{}, "", "", documentError(err)
 	}
-	return nil
+	warnTotal(doc.FindElement("//Invoice"), params)
+	warnSwapped(parsed, params)
+	if err := checkExpectedDate(parsed, params); err != nil {
+		return parsed, "", "", err
+	}
+	if err := checkTotal(parsed, params); err != nil {
+		return parsed, "", "", err
+	}
+	if err := checkAdvance(doc.FindElement("//Invoice")); err != nil {
+		return parsed, "", "", err
+	}
+
+	if params.Validate {
+		if err := validateParsed(parsed, params); err != nil {
+			return parsed, "", "", err
+		}
+	}
+	if err := params.escalate(); err != nil {
+		return parsed, "", "", err
+	}
+
+	// Select signer by TIN
+	signer, err = selectSigner(signer, params, parsed[0])
+	if err != nil {
+		return parsed, "", "", err
+	}
+
+	if err := checkReplay(parsed, params); err != nil {
+		return parsed, "", "", err
+	}
+
+	params.record(parsePhase, started)
+
+	// Generate
+	started = time.Now()
+	IIC, IICSignature, err := generateIIC(signer, parsed)
+	if err != nil {
+		return parsed, "", "", err
+	}
+	if err := reserveReplay(parsed, params); err != nil {
+		return parsed, "", "", err
+	}
+	params.record(signPhase, started)
+
+	setIICAs(doc.FindElement("//Invoice"), IIC, IICSignature, params.IICPlacement)
+	if params.GeneratedAt {
+		setGeneratedAt(doc.FindElement("//Invoice"), clockOrSystem(params.Clock).Now(), params.IICPlacement)
+	}
+	if params.PlainComment {
+		setPlainComment(doc.FindElement("//Invoice"), parsed)
+	}
+	return parsed, IIC, IICSignature, nil
+}
+
+// formatDocument indents doc according to style before it's saved
+func formatDocument(doc *etree.Document, style OutputStyle) {
+	switch {
+	case style == OutputCompact:
+		doc.Indent(etree.NoIndent)
+	case style > 0:
+		doc.Indent(int(style))
+	default:
+		doc.IndentTabs()
+	}
+	doc.Root().SetTail("")
 }
 
 // GenerateIIC generates IIC and IICSignature. Orders of parameters: TIN, IssueDateTime, InvOrdNum, BusinUnitCode, TCRCode, SoftCode, TotPrice
 func GenerateIIC(SafenetConfig *safenet.Config, params [7]string) (string, string, error) {
 	// Initialize Signer
-	signer := safenet.SafeNet{}
-	if err := signer.Initialize(SafenetConfig); err != nil {
+	signer, err := initializeSafeNet(SafenetConfig, 0, nil)
+	if err != nil {
 		return "", "", err
 	}
-	defer signer.Finalize()
+	defer finalizeSafeNet(signer)
+
+	return generateIIC(signer, params)
+}
 
-	plainIIC := fmt.Sprintf(
+// digestForSigning computes digest signed by generateIIC. It's always DigestForIIC, but tests may
+// replace it to produce deliberately wrong IICs for verification failure paths
+var digestForSigning = DigestForIIC
+
+// generateIIC generates IIC and IICSignature using given signer
+func generateIIC(signer Signer, params [7]string) (string, string, error) {
+	return GenerateIICFromDigest(signer, digestForSigning(params))
+}
+
+// PlainIIC concatenates parameters into the string which is hashed for IIC. Orders of parameters are the same as for GenerateIIC
+func PlainIIC(params [7]string) string {
+	return fmt.Sprintf(
 		"%v|%v|%v|%v|%v|%v|%v",
 		params[0], // TIN
 		params[1], // IssueDateTime
@@ -71,60 +347,125 @@ func GenerateIIC(SafenetConfig *safenet.Config, params [7]string) (string, strin
 		params[5], // SoftCode
 		params[6], // TotPrice
 	)
+}
 
-	hasher := crypto.SHA256.New()
-	_, err := hasher.Write([]byte(plainIIC))
-	if err != nil {
-		return "", "", err
-	}
-	sha256IIC := hasher.Sum(nil)
+// sha256Pool and md5Pool reuse hashers across invoices, avoiding an allocation per hasher in bulk runs.
+// Hashers are reset before they're put back
+var (
+	sha256Pool = sync.Pool{New: func() interface{} { return crypto.SHA256.New() }}
+	md5Pool    = sync.Pool{New: func() interface{} { return crypto.MD5.New() }}
+)
 
-	IICSignature, err := signer.SignPKCS1v15(sha256IIC)
-	if err != nil {
-		return "", "", err
+// sumOf returns hash of data computed with a hasher from given pool
+func sumOf(pool *sync.Pool, data []byte) []byte {
+	hasher := pool.Get().(hash.Hash)
+	defer func() {
+		hasher.Reset()
+		pool.Put(hasher)
+	}()
+	hasher.Write(data)
+	return hasher.Sum(nil)
+}
+
+// DigestForIIC returns sha256 hash of the plain IIC string, which is signed for IICSignature
+func DigestForIIC(params [7]string) []byte {
+	return sumOf(&sha256Pool, []byte(PlainIIC(params)))
+}
+
+// GenerateIICFromDigest signs already computed sha256 digest and returns IIC and IICSignature in hex
+func GenerateIICFromDigest(signer Signer, digest []byte) (string, string, error) {
+	if len(digest) != crypto.SHA256.Size() {
+		return "", "", fmt.Errorf("digest must be %d bytes long, got %d", crypto.SHA256.Size(), len(digest))
 	}
-	hasher = crypto.MD5.New()
-	_, err = hasher.Write(IICSignature)
+
+	IICSignature, err := signer.SignPKCS1v15(digest)
 	if err != nil {
-		return "", "", err
+		return "", "", signerError(err)
 	}
-	IIC := hasher.Sum(nil)
+	IIC := sumOf(&md5Pool, IICSignature)
 
-	return fmt.Sprintf("%x", IIC), fmt.Sprintf("%x", IICSignature), err
+	return fmt.Sprintf("%x", IIC), fmt.Sprintf("%x", IICSignature), nil
 }
 
-// Parse retrieves values necessary for IIC generation from given doc
-func parse(doc *etree.Document) ([7]string, error) {
-	TIN, err := attributeOfElement("//Seller", "IDNum", doc)
-	if err != nil {
-		return [7]string{}, err
-	}
-	IssueDateTime, err := attributeOfElement("//Invoice", "IssueDateTime", doc)
-	if err != nil {
-		return [7]string{}, err
+// GenerateIICFromPlain signs plain IIC string exactly as given, e.g. one reported by the authority for
+// a disputed invoice, and returns IIC and IICSignature in hex. The string isn't checked, see CheckPlainIIC
+func GenerateIICFromPlain(signer Signer, plain string) (string, string, error) {
+	return GenerateIICFromDigest(signer, sumOf(&sha256Pool, []byte(plain)))
+}
+
+// CheckPlainIIC reports plain IIC string which doesn't consist of seven values separated by pipes like
+// PlainIIC returns. Such a string can still be signed, but likely has been truncated or mangled
+func CheckPlainIIC(plain string) error {
+	if separators := strings.Count(plain, "|"); separators != len(FieldNames)-1 {
+		return fmt.Errorf("plain IIC %q has %d separators, expected %d", plain, separators, len(FieldNames)-1)
 	}
-	InvOrdNum, err := attributeOfElement("//Invoice", "InvOrdNum", doc)
-	if err != nil {
-		return [7]string{}, err
+	return nil
+}
+
+// Parse retrieves values necessary for IIC generation from the first Invoice of given doc
+func parse(doc *etree.Document, opts ParseOptions) ([7]string, error) {
+	invoice := doc.FindElement("//Invoice")
+	if invoice == nil {
+		return [7]string{}, fmt.Errorf("can't find element %s", "//Invoice")
 	}
-	BusinUnitCode, err := attributeOfElement("//Invoice", "BusinUnitCode", doc)
-	if err != nil {
-		return [7]string{}, err
+	return parseInvoice(doc, invoice, opts)
+}
+
+// parseInvoice retrieves values necessary for IIC generation from given Invoice element of doc
+func parseInvoice(doc *etree.Document, invoice *etree.Element, opts ParseOptions) ([7]string, error) {
+	params, errs := lookupInvoice(doc, invoice, opts)
+	if len(errs) > 0 {
+		return [7]string{}, errs[0]
 	}
-	TCRCode, err := attributeOfElement("//Invoice", "TCRCode", doc)
-	if err != nil {
-		return [7]string{}, err
+	return params, nil
+}
+
+// lookupInvoice retrieves values necessary for IIC generation from given Invoice element of doc,
+// collecting errors of every value which can't be found
+func lookupInvoice(doc *etree.Document, invoice *etree.Element, opts ParseOptions) ([7]string, []error) {
+	params, fieldErrs := lookupFields(doc, invoice, opts)
+	errs := []error{}
+	for _, err := range fieldErrs {
+		if err != nil {
+			errs = append(errs, err)
+		}
 	}
-	SoftCode, err := attributeOfElement("//Invoice", "SoftCode", doc)
+	return params, errs
+}
+
+// lookupFields is the same as lookupInvoice, but returns error of every value at its index
+func lookupFields(doc *etree.Document, invoice *etree.Element, opts ParseOptions) ([7]string, [7]error) {
+	params := [7]string{}
+	errs := [7]error{}
+
+	seller, err := sellerOf(doc, invoice)
 	if err != nil {
-		return [7]string{}, err
+		errs[0] = err
+	} else {
+		params[0], errs[0] = fieldOf(seller, opts.SellerID.attr(), opts)
 	}
-	TotPrice, err := attributeOfElement("//Invoice", "TotPrice", doc)
-	if err != nil {
-		return [7]string{}, err
+	params[1], errs[1] = issueDateTime(invoice, opts)
+	for i, attrName := range []string{"InvOrdNum", "BusinUnitCode", "TCRCode", "SoftCode", opts.totalAttr()} {
+		params[i+2], errs[i+2] = fieldOf(invoice, attrName, opts)
 	}
 
-	return [7]string{TIN, IssueDateTime, InvOrdNum, BusinUnitCode, TCRCode, SoftCode, TotPrice}, nil
+	return params, errs
+}
+
+// SetIIC writes IIC and IICSignature attributes into given Invoice element, replacing existing ones
+func SetIIC(invoice *etree.Element, iic string, iicSignature string) {
+	invoice.RemoveAttr("IIC")
+	invoice.CreateAttr("IIC", iic)
+
+	invoice.RemoveAttr("IICSignature")
+	invoice.CreateAttr("IICSignature", iicSignature)
+}
+
+// attributeOf returns value of an attribute if it's found in given element
+func attributeOf(elem *etree.Element, attrName string) (string, error) {
+	return mapAttrib(attrName, elem, func(attr *etree.Attr) (string, error) {
+		return attr.Value, nil
+	})
 }
 
 // AttributeOfElement returns an attribute value if it's found in given element
@@ -0,0 +1,172 @@
+package iic
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteIICParams(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	tests := []struct {
+		name   string
+		params *Params
+		want   string
+	}{
+		{"nil", nil, "params: nil"},
+		{"no signer", &Params{InFile: "in.xml", OutFile: "out.xml"}, "params: neither Registry, Signer nor SafenetConfig is set"},
+		{"no InFile", &Params{Signer: signer, OutFile: "out.xml"}, "params: InFile is empty"},
+		{"no OutFile", &Params{Signer: signer, InFile: "in.xml"}, "params: OutFile is empty"},
+	}
+	for _, test := range tests {
+		err := WriteIIC(test.params)
+		if err == nil || !strings.HasPrefix(err.Error(), test.want) {
+			t.Errorf("%s: WriteIIC returned %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestSumOfReset(t *testing.T) {
+	inputs := []string{PlainIIC(testInvoiceFields), "", "a", PlainIIC(testInvoiceFields)}
+	for i := 0; i < 3; i++ {
+		for _, input := range inputs {
+			sha := sha256.Sum256([]byte(input))
+			if sum := sumOf(&sha256Pool, []byte(input)); !bytes.Equal(sum, sha[:]) {
+				t.Errorf("sha256 of %q is %x, want %x", input, sum, sha)
+			}
+			md := md5.Sum([]byte(input))
+			if sum := sumOf(&md5Pool, []byte(input)); !bytes.Equal(sum, md[:]) {
+				t.Errorf("md5 of %q is %x, want %x", input, sum, md)
+			}
+		}
+	}
+}
+
+// BenchmarkSumOf measures hashing with pooled hashers, compare allocations with BenchmarkSumOfUnpooled
+// which allocates hashers per invoice like GenerateIIC did before pooling
+func BenchmarkSumOf(b *testing.B) {
+	plain := []byte(PlainIIC(testInvoiceFields))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sumOf(&md5Pool, sumOf(&sha256Pool, plain))
+	}
+}
+
+func BenchmarkSumOfUnpooled(b *testing.B) {
+	plain := []byte(PlainIIC(testInvoiceFields))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sha := crypto.SHA256.New()
+		sha.Write(plain)
+		md := crypto.MD5.New()
+		md.Write(sha.Sum(nil))
+		md.Sum(nil)
+	}
+}
+
+func TestWriteIICMultipleInvoices(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	in := writeTestFile(t, "in.xml", testBundle("1", "2"))
+	out := filepath.Join(t.TempDir(), "out.xml")
+
+	err := WriteIIC(&Params{Signer: signer, InFile: in, OutFile: out})
+	if !errors.Is(err, ErrMultipleInvoices) || !strings.Contains(err.Error(), "WriteIICAll") {
+		t.Errorf("WriteIIC returned %v, want ErrMultipleInvoices pointing to WriteIICAll", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("output is written: %v", err)
+	}
+
+	logged := &bytes.Buffer{}
+	params := &Params{Signer: signer, InFile: in, OutFile: out, SignFirstInvoice: true, Logger: log.New(logged, "", 0)}
+	if err := WriteIIC(params); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logged.String(), "only the first one is signed") {
+		t.Errorf("no warning is logged: %q", logged)
+	}
+	doc, _, err := readDocument(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReadIICAt(doc, 0); err != nil {
+		t.Errorf("first invoice: %v", err)
+	}
+	if _, _, err := ReadIICAt(doc, 1); err == nil {
+		t.Error("second invoice is signed")
+	}
+}
+
+func TestWriteIICQuoting(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	clean := `<?xml version="1.0" encoding="UTF-8"?>
+<Invoices>
+  <Invoice IssueDateTime="2019-06-12T17:05:43+02:00" InvOrdNum="9952" BusinUnitCode="bb&amp;123" TCRCode="cc123cc123" SoftCode="O&apos;Soft" TotPrice="99.01">
+    <Seller IDType="TIN" IDNum="12345678" Name="A &amp; B &quot;d.o.o.&quot;"/>
+  </Invoice>
+</Invoices>
+`
+	messy := `<?xml version='1.0' encoding='UTF-8'?>
+<Invoices>
+  <Invoice IssueDateTime = '2019-06-12T17:05:43+02:00'
+	InvOrdNum='9952'   BusinUnitCode='bb&amp;123'
+		TCRCode="cc123cc123" SoftCode="O&apos;Soft" TotPrice  ='99.01'>
+    <Seller  IDType='TIN' IDNum='12345678' Name='A &amp; B "d.o.o."' />
+  </Invoice>
+</Invoices>
+`
+	want := [7]string{"12345678", "2019-06-12T17:05:43+02:00", "9952", "bb&123", "cc123cc123", "O'Soft", "99.01"}
+
+	outputs := [][]byte{}
+	for _, content := range []string{clean, messy} {
+		fields, err := parse(readTestDocument(t, content), ParseOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fields != want {
+			t.Errorf("fields are %q, want %q", fields, want)
+		}
+
+		out := filepath.Join(t.TempDir(), "out.xml")
+		params := &Params{Signer: signer, InFile: writeTestFile(t, "in.xml", content), OutFile: out, Reproducible: true}
+		if err := WriteIIC(params); err != nil {
+			t.Fatal(err)
+		}
+		buf, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, buf)
+
+		doc, _, err := readDocument(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fields, err := parse(doc, ParseOptions{}); err != nil || fields != want {
+			t.Errorf("saved fields are %q, %v, want %q", fields, err, want)
+		}
+		if err := verifyTestDocument(t, signer, doc); err != nil {
+			t.Error(err)
+		}
+	}
+	// the XML declaration is kept as written, see OutputStyle
+	withoutDeclaration := func(buf []byte) []byte {
+		return buf[bytes.IndexByte(buf, '\n'):]
+	}
+	if !bytes.Equal(withoutDeclaration(outputs[0]), withoutDeclaration(outputs[1])) {
+		t.Errorf("outputs differ:\n%s\n%s", outputs[0], outputs[1])
+	}
+	for _, escaped := range []string{`BusinUnitCode="bb&amp;123"`, `SoftCode="O&apos;Soft"`, `Name="A &amp; B &quot;d.o.o.&quot;"`} {
+		if !bytes.Contains(outputs[1], []byte(escaped)) {
+			t.Errorf("output doesn't contain %s:\n%s", escaped, outputs[1])
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package iictest
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/noshto/iic"
+)
+
+// AssertValidIIC fails the test unless IIC and IICSignature of the XML document doc are present and verify
+// against the PEM encoded certificate, see iic.VerifyIICFile. Returns whether the assertion holds
+func AssertValidIIC(t testing.TB, certPEM []byte, doc []byte) bool {
+	t.Helper()
+	if err := verify(certPEM, doc); err != nil {
+		t.Errorf("document has no valid IIC: %v", err)
+		return false
+	}
+	return true
+}
+
+// AssertInvalidIIC fails the test if IIC and IICSignature of the XML document doc verify against the PEM encoded
+// certificate, e.g. after the document was tampered with. Returns whether the assertion holds
+func AssertInvalidIIC(t testing.TB, certPEM []byte, doc []byte) bool {
+	t.Helper()
+	if err := verify(certPEM, doc); err == nil {
+		t.Errorf("document has IIC valid for the certificate, expected it to be invalid")
+		return false
+	}
+	return true
+}
+
+// verify saves doc into a temporary file and verifies it with iic.VerifyIICFile
+func verify(certPEM []byte, doc []byte) error {
+	file, err := ioutil.TempFile("", "iictest-*.xml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(doc); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return iic.VerifyIICFile(certPEM, file.Name())
+}
@@ -0,0 +1,171 @@
+// Package iictest provides helpers for testing integrations of the iic package without the real
+// fiscalization service or hardware
+package iictest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/noshto/iic"
+)
+
+// DefaultFIC is the FIC returned by CISServer unless another one is set
+const DefaultFIC = "3a5a1d72-8b6e-4d6e-9f15-5b2c0c0c2f11"
+
+// Behavior selects how CISServer responds to requests
+type Behavior int
+
+const (
+	// Respond validates the request and returns FIC, or a SOAP fault if the request is invalid
+	Respond Behavior = iota
+	// Fault always returns a SOAP fault
+	Fault
+	// MalformedResponse returns a body which isn't valid XML
+	MalformedResponse
+	// Timeout never responds, until the client gives up or the server is closed
+	Timeout
+)
+
+// CISServer mimics the endpoint accepting RegisterInvoiceRequest
+type CISServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	behavior Behavior
+	fic      string
+	requests []*etree.Document
+	stop     chan struct{}
+}
+
+// NewCISServer starts CISServer responding with DefaultFIC
+func NewCISServer() *CISServer {
+	s := &CISServer{fic: DefaultFIC, stop: make(chan struct{})}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// SetBehavior changes how subsequent requests are answered
+func (s *CISServer) SetBehavior(behavior Behavior) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.behavior = behavior
+}
+
+// SetFIC changes FIC returned to valid requests
+func (s *CISServer) SetFIC(fic string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fic = fic
+}
+
+// Requests returns documents received so far
+func (s *CISServer) Requests() []*etree.Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*etree.Document{}, s.requests...)
+}
+
+// Close releases hanging Timeout requests and shuts the server down
+func (s *CISServer) Close() {
+	close(s.stop)
+	s.Server.Close()
+}
+
+func (s *CISServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	behavior, fic := s.behavior, s.fic
+	s.mu.Unlock()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeFault(w, err.Error())
+		return
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(body); err != nil {
+		writeFault(w, fmt.Sprintf("malformed request: %v", err))
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, doc)
+	s.mu.Unlock()
+
+	switch behavior {
+	case Fault:
+		writeFault(w, "simulated fault")
+	case MalformedResponse:
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		fmt.Fprint(w, "<env:Envelope><FIC>")
+	case Timeout:
+		select {
+		case <-r.Context().Done():
+		case <-s.stop:
+		}
+	default:
+		if err := validateRequest(doc); err != nil {
+			writeFault(w, err.Error())
+			return
+		}
+		writeFIC(w, doc, fic)
+	}
+}
+
+// validateRequest checks structure of RegisterInvoiceRequest
+func validateRequest(doc *etree.Document) error {
+	request := doc.FindElement("//RegisterInvoiceRequest")
+	if request == nil {
+		return fmt.Errorf("can't find element RegisterInvoiceRequest")
+	}
+	header := request.SelectElement("Header")
+	if header == nil || header.SelectAttr("UUID") == nil || header.SelectAttr("SendDateTime") == nil {
+		return fmt.Errorf("Header with UUID and SendDateTime is required")
+	}
+	invoice := request.SelectElement("Invoice")
+	if invoice == nil {
+		return fmt.Errorf("can't find element Invoice")
+	}
+	if invoice.SelectAttr("IIC") == nil || invoice.SelectAttr("IICSignature") == nil {
+		return fmt.Errorf("Invoice has no IIC or IICSignature")
+	}
+	return iic.ValidateDocument(doc, iic.ParseOptions{}, iic.ValidateOptions{})
+}
+
+// writeFIC responds with RegisterInvoiceResponse carrying given FIC
+func writeFIC(w http.ResponseWriter, request *etree.Document, fic string) {
+	requestUUID := request.FindElement("//Header").SelectAttrValue("UUID", "")
+
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+	envelope := doc.CreateElement("env:Envelope")
+	envelope.CreateAttr("xmlns:env", "http://schemas.xmlsoap.org/soap/envelope/")
+	response := envelope.CreateElement("env:Body").CreateElement("RegisterInvoiceResponse")
+	response.CreateAttr("xmlns", "https://efi.tax.gov.me/fs/schema")
+	response.CreateAttr("Id", "Response")
+	response.CreateAttr("Version", "1")
+	header := response.CreateElement("Header")
+	header.CreateAttr("RequestUUID", requestUUID)
+	header.CreateAttr("SendDateTime", time.Now().Format(time.RFC3339))
+	response.CreateElement("FIC").SetText(fic)
+
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	doc.WriteTo(w)
+}
+
+// writeFault responds with SOAP fault carrying given message
+func writeFault(w http.ResponseWriter, message string) {
+	doc := etree.NewDocument()
+	envelope := doc.CreateElement("env:Envelope")
+	envelope.CreateAttr("xmlns:env", "http://schemas.xmlsoap.org/soap/envelope/")
+	fault := envelope.CreateElement("env:Body").CreateElement("env:Fault")
+	fault.CreateElement("faultcode").SetText("env:Client")
+	fault.CreateElement("faultstring").SetText(message)
+
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	doc.WriteTo(w)
+}
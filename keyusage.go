@@ -0,0 +1,92 @@
+package iic
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+)
+
+// keyUsageNames names bits of x509.KeyUsage in the order of RFC 5280
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digitalSignature"},
+	{x509.KeyUsageContentCommitment, "contentCommitment"},
+	{x509.KeyUsageKeyEncipherment, "keyEncipherment"},
+	{x509.KeyUsageDataEncipherment, "dataEncipherment"},
+	{x509.KeyUsageKeyAgreement, "keyAgreement"},
+	{x509.KeyUsageCertSign, "keyCertSign"},
+	{x509.KeyUsageCRLSign, "cRLSign"},
+	{x509.KeyUsageEncipherOnly, "encipherOnly"},
+	{x509.KeyUsageDecipherOnly, "decipherOnly"},
+}
+
+// extKeyUsageNames names extended key usages known to crypto/x509
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                        "any",
+	x509.ExtKeyUsageServerAuth:                 "serverAuth",
+	x509.ExtKeyUsageClientAuth:                 "clientAuth",
+	x509.ExtKeyUsageCodeSigning:                "codeSigning",
+	x509.ExtKeyUsageEmailProtection:            "emailProtection",
+	x509.ExtKeyUsageIPSECEndSystem:             "ipsecEndSystem",
+	x509.ExtKeyUsageIPSECTunnel:                "ipsecTunnel",
+	x509.ExtKeyUsageIPSECUser:                  "ipsecUser",
+	x509.ExtKeyUsageTimeStamping:               "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:                "OCSPSigning",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto: "msSGC",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:  "nsSGC",
+}
+
+// signingExtKeyUsages lists extended key usages of certificates issued for signing by a person or a company.
+// Document signing usages unknown to crypto/x509 are accepted as well
+var signingExtKeyUsages = map[x509.ExtKeyUsage]bool{
+	x509.ExtKeyUsageAny:             true,
+	x509.ExtKeyUsageClientAuth:      true,
+	x509.ExtKeyUsageEmailProtection: true,
+}
+
+// CheckKeyUsage checks that the certificate allows signing IICs: its key usage, if restricted, includes
+// digitalSignature, and its extended key usage, if restricted, isn't limited to e.g. TLS servers or time stamping.
+// The authority rejects IICs of other certificates, so it fails with ErrKeyUsage naming usages found
+func CheckKeyUsage(cert *x509.Certificate) error {
+	if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return fmt.Errorf("%w: certificate %s has key usage %s", ErrKeyUsage, cert.Subject, strings.Join(keyUsagesOf(cert), ", "))
+	}
+	if len(cert.ExtKeyUsage) == 0 || len(cert.UnknownExtKeyUsage) > 0 {
+		return nil
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		if signingExtKeyUsages[usage] {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: certificate %s has extended key usage %s", ErrKeyUsage, cert.Subject, strings.Join(extKeyUsagesOf(cert), ", "))
+}
+
+// keyUsagesOf returns names of key usages of cert
+func keyUsagesOf(cert *x509.Certificate) []string {
+	names := []string{}
+	for _, usage := range keyUsageNames {
+		if cert.KeyUsage&usage.usage != 0 {
+			names = append(names, usage.name)
+		}
+	}
+	return names
+}
+
+// extKeyUsagesOf returns names of extended key usages of cert, unknown ones as their OIDs
+func extKeyUsagesOf(cert *x509.Certificate) []string {
+	names := []string{}
+	for _, usage := range cert.ExtKeyUsage {
+		if name, ok := extKeyUsageNames[usage]; ok {
+			names = append(names, name)
+		} else {
+			names = append(names, fmt.Sprintf("%d", usage))
+		}
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		names = append(names, oid.String())
+	}
+	return names
+}
@@ -0,0 +1,66 @@
+package iic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// LintReport lists problems of a document found by LintDocument. Errors make signing fail or the IIC
+// rejected, Warnings are suspicions of a valid-looking but wrong IIC
+type LintReport struct {
+	Errors   []string `json:"Errors"`
+	Warnings []string `json:"Warnings"`
+}
+
+// LintDocument checks every Invoice of doc with validations of ValidateDocument and heuristics for common
+// mistakes: swapped InvOrdNum and TCRCode, values with surrounding whitespace, which IIC takes verbatim,
+// and a total other than TotPrice. No signer is needed
+func LintDocument(doc *etree.Document, parseOpts ParseOptions, opts ValidateOptions) LintReport {
+	report := LintReport{Errors: []string{}, Warnings: []string{}}
+	invoices := doc.FindElements("//Invoice")
+	if len(invoices) == 0 {
+		report.Errors = append(report.Errors, fmt.Sprintf("can't find element %s", "//Invoice"))
+		return report
+	}
+	if err := ValidateCompanionFields(doc); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	for i, invoice := range invoices {
+		prefix := fmt.Sprintf("invoice %d: ", i+1)
+		errorf := func(err error) {
+			report.Errors = append(report.Errors, prefix+err.Error())
+		}
+		warnf := func(format string, v ...interface{}) {
+			report.Warnings = append(report.Warnings, prefix+fmt.Sprintf(format, v...))
+		}
+
+		fields, errs := lookupInvoice(doc, invoice, parseOpts)
+		for _, err := range errs {
+			errorf(err)
+		}
+		if len(errs) > 0 {
+			continue
+		}
+		if err := validateFields(fields, parseOpts.SellerID, opts); err != nil {
+			for _, err := range err.(*ValidationError).Errors {
+				errorf(err)
+			}
+		}
+
+		if suspicion := swappedSuspicion(fields); len(suspicion) > 0 {
+			warnf("%s", suspicion)
+		}
+		for j, value := range fields {
+			if strings.TrimSpace(value) != value {
+				warnf("%s %q has surrounding whitespace, which IIC includes", FieldNames[j], value)
+			}
+		}
+		if name := parseOpts.totalAttr(); name != "TotPrice" && invoice.SelectAttr("TotPrice") != nil {
+			warnf("IIC uses %s as total, but the invoice has TotPrice which the IIC must use", name)
+		}
+	}
+	return report
+}
@@ -0,0 +1,96 @@
+package iic
+
+import (
+	"fmt"
+
+	"github.com/beevik/etree"
+)
+
+// MergeAndSign combines Invoice elements of inFiles into a single bundle, generates IIC for every invoice and
+// saves the bundle to outFile. The first file provides the root and everything besides its invoices, invoices
+// of other files are appended after its last Invoice. Files must have the same root element, namespace and
+// schema version. Returns results of invoices in bundle order, failed invoices are saved without IIC
+func MergeAndSign(signer Signer, inFiles []string, outFile string) ([]InvoiceResult, error) {
+	if len(inFiles) == 0 {
+		return nil, fmt.Errorf("no input files")
+	}
+	bundle, err := mergeDocuments(inFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &Params{Signer: signer, OutFile: outFile}
+	results := signInvoices(signer, bundle, params)
+	formatDocument(bundle, params.OutputStyle)
+	if err := writeDocument(bundle, outFile, false, params); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// mergeDocuments reads files and appends invoices of every file to the first one
+func mergeDocuments(files []string) (*etree.Document, error) {
+	bundle, _, err := readDocument(files[0])
+	if err != nil {
+		return nil, documentError(fmt.Errorf("%s: %v", files[0], err))
+	}
+	invoices := bundle.FindElements("//Invoice")
+	if len(invoices) == 0 {
+		return nil, documentError(fmt.Errorf("%s: can't find element %s", files[0], "//Invoice"))
+	}
+	last := invoices[len(invoices)-1]
+
+	for _, file := range files[1:] {
+		doc, _, err := readDocument(file)
+		if err != nil {
+			return nil, documentError(fmt.Errorf("%s: %v", file, err))
+		}
+		if err := checkCompatible(bundle, doc); err != nil {
+			return nil, documentError(fmt.Errorf("%s: %v", file, err))
+		}
+		for _, invoice := range doc.FindElements("//Invoice") {
+			merged := invoice.Copy()
+			last.Parent().InsertChildAt(last.Index()+1, merged)
+			last = merged
+			if err := checkSameSeller(doc, invoice, bundle, merged); err != nil {
+				return nil, documentError(fmt.Errorf("%s: %v", file, err))
+			}
+		}
+	}
+	return bundle, nil
+}
+
+// checkCompatible checks that doc has the same root element, namespace and schema version as bundle
+func checkCompatible(bundle *etree.Document, doc *etree.Document) error {
+	a, b := bundle.Root(), doc.Root()
+	if b == nil {
+		return fmt.Errorf("document has no root element")
+	}
+	if a.Tag != b.Tag {
+		return fmt.Errorf("root element %s differs from %s", b.Tag, a.Tag)
+	}
+	if a.NamespaceURI() != b.NamespaceURI() {
+		return fmt.Errorf("namespace %q differs from %q", b.NamespaceURI(), a.NamespaceURI())
+	}
+	if a.SelectAttrValue("Version", "") != b.SelectAttrValue("Version", "") {
+		return fmt.Errorf("schema version %q differs from %q", b.SelectAttrValue("Version", ""), a.SelectAttrValue("Version", ""))
+	}
+	return nil
+}
+
+// checkSameSeller checks that the merged invoice resolves to a Seller with the same IDNum as the original,
+// as Sellers outside of invoices aren't merged
+func checkSameSeller(doc *etree.Document, invoice *etree.Element, bundle *etree.Document, merged *etree.Element) error {
+	original, err := sellerOf(doc, invoice)
+	if err != nil {
+		return err
+	}
+	resolved, err := sellerOf(bundle, merged)
+	if err != nil {
+		return err
+	}
+	if a, b := original.SelectAttrValue("IDNum", ""), resolved.SelectAttrValue("IDNum", ""); a != b {
+		return fmt.Errorf("invoice of Seller %s would be merged under Seller %s", a, b)
+	}
+	return nil
+}
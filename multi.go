@@ -0,0 +1,294 @@
+package iic
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/beevik/etree"
+)
+
+// InvoiceStatus describes what happened to an invoice of a multi-invoice document
+type InvoiceStatus int
+
+const (
+	// InvoiceSigned means that IIC was generated for the invoice
+	InvoiceSigned InvoiceStatus = iota
+	// InvoiceSkipped means that the invoice already had valid IIC and was left untouched
+	InvoiceSkipped
+	// InvoiceFailed means that IIC couldn't be generated, see InvoiceResult.Err
+	InvoiceFailed
+)
+
+// String returns human readable name of the status
+func (s InvoiceStatus) String() string {
+	switch s {
+	case InvoiceSigned:
+		return "signed"
+	case InvoiceSkipped:
+		return "skipped"
+	case InvoiceFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// InvoiceResult represents outcome of a single invoice of a multi-invoice document.
+// Index is position of the invoice among Invoice elements of the document. Warnings are those about
+// the invoice and the whole document
+type InvoiceResult struct {
+	Index        int
+	Status       InvoiceStatus
+	Fields       [7]string
+	IIC          string
+	IICSignature string
+	Warnings     []Warning
+	Err          error
+}
+
+// InvoiceSummary counts invoices by status
+type InvoiceSummary struct {
+	Signed  int
+	Skipped int
+	Failed  int
+}
+
+// Summarize counts invoice results by status
+func Summarize(results []InvoiceResult) InvoiceSummary {
+	summary := InvoiceSummary{}
+	for _, result := range results {
+		switch result.Status {
+		case InvoiceSigned:
+			summary.Signed++
+		case InvoiceSkipped:
+			summary.Skipped++
+		case InvoiceFailed:
+			summary.Failed++
+		}
+	}
+	return summary
+}
+
+// FindDuplicateIICs returns indexes of results sharing an IIC, by the IIC. Shared IIC means identical values,
+// usually a reused ordinal, which the authority rejects. Results without IIC are ignored
+func FindDuplicateIICs(results []InvoiceResult) map[string][]int {
+	indexes := map[string][]int{}
+	for i, result := range results {
+		if len(result.IIC) > 0 {
+			indexes[result.IIC] = append(indexes[result.IIC], i)
+		}
+	}
+	duplicates := map[string][]int{}
+	for IIC, shared := range indexes {
+		if len(shared) > 1 {
+			duplicates[IIC] = shared
+		}
+	}
+	return duplicates
+}
+
+// WriteIICAll generates IIC for every Invoice of params.InFile and saves the result to params.OutFile.
+// Invoices which failed are left untouched and reported in results
+func WriteIICAll(params *Params) ([]InvoiceResult, error) {
+	if err := validateParams(params); err != nil {
+		return nil, err
+	}
+
+	var results []InvoiceResult
+	err := withSigner(params, func(signer Signer) error {
+		var err error
+		results, err = writeIICAll(signer, params)
+		return err
+	})
+	return results, err
+}
+
+// writeIICAll generates IIC for every Invoice of params.InFile using given signer
+func writeIICAll(signer Signer, params *Params) ([]InvoiceResult, error) {
+	doc, hasBOM, err := readDocumentWith(params.InFile, params.ReadSettings)
+	if err != nil {
+		return nil, documentError(err)
+	}
+	if err := checkDocument(doc, params); err != nil {
+		return nil, documentError(err)
+	}
+	if err := applyOverrides(doc, params); err != nil {
+		return nil, documentError(err)
+	}
+	if err := params.escalate(); err != nil {
+		return nil, err
+	}
+
+	var results []InvoiceResult
+	if params.AllOrNothing {
+		staged, err := stage(signer, doc, params)
+		if err != nil {
+			params.settleReplay(false)
+			return staged.Results, err
+		}
+		results = staged.apply()
+	} else {
+		results = signInvoices(signer, doc, params)
+	}
+	if params.GeneratedAt {
+		setGeneratedAts(doc, results, clockOrSystem(params.Clock).Now(), params.IICPlacement)
+	}
+	if params.PlainComment {
+		setPlainComments(doc, results)
+	}
+
+	formatDocument(doc, params.OutputStyle)
+
+	if err := writeDocument(doc, params.OutFile, hasBOM, params); err != nil {
+		params.settleReplay(false)
+		return results, err
+	}
+	return results, params.settleReplay(true)
+}
+
+// signInvoices generates IIC for every Invoice of doc and writes it into invoices which were signed
+func signInvoices(signer Signer, doc *etree.Document, params *Params) []InvoiceResult {
+	invoices, results := computeInvoices(signer, doc, params)
+	setIICs(invoices, results, params.IICPlacement)
+	return results
+}
+
+// computeInvoices generates IIC for every Invoice of doc in order of processingOrder without modifying doc.
+// Results are in document order
+func computeInvoices(signer Signer, doc *etree.Document, params *Params) ([]*etree.Element, []InvoiceResult) {
+	invoices := doc.FindElements("//Invoice")
+	results := make([]InvoiceResult, len(invoices))
+	prepared := params.takeWarnings()
+	for _, i := range processingOrder(invoices, params.ParseOptions) {
+		results[i] = computeInvoice(signer, doc, invoices[i], params)
+		results[i].Index = i
+		results[i].Warnings = append(warningsOf(prepared, i), aboutInvoice(params.takeWarnings(), i)...)
+	}
+	return invoices, results
+}
+
+// setIICs writes IIC of signed results into corresponding invoices according to placement
+func setIICs(invoices []*etree.Element, results []InvoiceResult, placement IICPlacement) {
+	for i, result := range results {
+		if result.Status == InvoiceSigned {
+			setIICAs(invoices[i], result.IIC, result.IICSignature, placement)
+		}
+	}
+}
+
+// processingOrder returns indexes of invoices sorted by InvOrdNum, so batches are reproducible run-to-run.
+// InvOrdNum is read according to opts. Invoices with equal, missing or non-numeric ordinals keep their
+// document order, the latter go last
+func processingOrder(invoices []*etree.Element, opts ParseOptions) []int {
+	ordinals := make([]int, len(invoices))
+	order := make([]int, len(invoices))
+	for i, invoice := range invoices {
+		order[i] = i
+		value, _ := fieldOf(invoice, "InvOrdNum", opts)
+		ordinal, err := strconv.Atoi(value)
+		if err != nil {
+			ordinal = math.MaxInt32
+		}
+		ordinals[i] = ordinal
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return ordinals[order[a]] < ordinals[order[b]]
+	})
+	return order
+}
+
+// computeInvoice generates IIC for given Invoice of doc, unless params.SkipValid is set and the invoice
+// already has IIC valid for the signer's certificate
+func computeInvoice(signer Signer, doc *etree.Document, invoice *etree.Element, params *Params) InvoiceResult {
+	parsed, err := parseInvoice(doc, invoice, params.ParseOptions)
+	if err != nil {
+		return InvoiceResult{Status: InvoiceFailed, Err: documentError(err)}
+	}
+	warnTotal(invoice, params)
+	warnSwapped(parsed, params)
+	if err := checkExpectedDate(parsed, params); err != nil {
+		return InvoiceResult{Status: InvoiceFailed, Fields: parsed, Err: err}
+	}
+	if err := checkTotal(parsed, params); err != nil {
+		return InvoiceResult{Status: InvoiceFailed, Fields: parsed, Err: err}
+	}
+	if err := checkAdvance(invoice); err != nil {
+		return InvoiceResult{Status: InvoiceFailed, Fields: parsed, Err: err}
+	}
+	if params.Validate {
+		if err := validateParsed(parsed, params); err != nil {
+			return InvoiceResult{Status: InvoiceFailed, Fields: parsed, Err: err}
+		}
+	}
+	if err := params.escalate(); err != nil {
+		return InvoiceResult{Status: InvoiceFailed, Fields: parsed, Err: err}
+	}
+	signer, err = selectSigner(signer, params, parsed[0])
+	if err != nil {
+		return InvoiceResult{Status: InvoiceFailed, Fields: parsed, Err: err}
+	}
+
+	if params.SkipValid {
+		if IIC, IICSignature, ok := validIIC(signer, invoice, parsed); ok {
+			return InvoiceResult{Status: InvoiceSkipped, Fields: parsed, IIC: IIC, IICSignature: IICSignature}
+		}
+	}
+
+	if err := checkReplay(parsed, params); err != nil {
+		return InvoiceResult{Status: InvoiceFailed, Fields: parsed, Err: err}
+	}
+
+	IIC, IICSignature, err := generateIIC(signer, parsed)
+	if err != nil {
+		return InvoiceResult{Status: InvoiceFailed, Fields: parsed, Err: err}
+	}
+	if err := reserveReplay(parsed, params); err != nil {
+		return InvoiceResult{Status: InvoiceFailed, Fields: parsed, Err: err}
+	}
+	return InvoiceResult{Status: InvoiceSigned, Fields: parsed, IIC: IIC, IICSignature: IICSignature}
+}
+
+// validIIC returns existing IIC and IICSignature of the invoice if they're valid for the signer's certificate.
+// Invoices of signers which don't provide certificate are never considered valid
+func validIIC(signer Signer, invoice *etree.Element, params [7]string) (string, string, bool) {
+	cert, err := certificateOf(signer)
+	if err != nil {
+		return "", "", false
+	}
+	IIC, IICSignature, err := iicOf(invoice)
+	if err != nil || len(IIC) == 0 || len(IICSignature) == 0 {
+		return "", "", false
+	}
+	if err := VerifyIIC(cert, params, IIC, IICSignature); err != nil {
+		return "", "", false
+	}
+	return IIC, IICSignature, true
+}
+
+// WriteIICFiltered generates IIC for invoices of doc whose values satisfy pred and writes it into them as
+// params.IICPlacement, leaving other invoices untouched. Values are read with params.ParseOptions. Returns results
+// of processed invoices in processing order, including invoices whose values can't be parsed, as pred can't be
+// evaluated for them. Ordinals of params.SeenStore are recorded once IICs are written into doc, like with Commit,
+// failing to record them is returned. Params may be nil
+func WriteIICFiltered(signer Signer, doc *etree.Document, pred func(InvoiceFields) bool, params *Params) ([]InvoiceResult, error) {
+	if params == nil {
+		params = &Params{}
+	}
+	invoices := doc.FindElements("//Invoice")
+	results := []InvoiceResult{}
+	for _, i := range processingOrder(invoices, params.ParseOptions) {
+		parsed, err := parseInvoice(doc, invoices[i], params.ParseOptions)
+		if err == nil && !pred(FieldsOf(parsed)) {
+			continue
+		}
+		result := computeInvoice(signer, doc, invoices[i], params)
+		result.Index = i
+		result.Warnings = aboutInvoice(params.takeWarnings(), i)
+		if result.Status == InvoiceSigned {
+			setIICAs(invoices[i], result.IIC, result.IICSignature, params.IICPlacement)
+		}
+		results = append(results, result)
+	}
+	return results, params.settleReplay(true)
+}
@@ -0,0 +1,128 @@
+package iic
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingSigner records digests it signs in order
+type recordingSigner struct {
+	Signer
+	mu      sync.Mutex
+	digests []string
+}
+
+func (s *recordingSigner) SignPKCS1v15(data []byte) ([]byte, error) {
+	s.mu.Lock()
+	s.digests = append(s.digests, string(data))
+	s.mu.Unlock()
+	return s.Signer.SignPKCS1v15(data)
+}
+
+// testElementBundle is the same as testBundle, but writes values of the IIC as child elements
+func testElementBundle(ordinals ...string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n<Invoices>\n")
+	for _, ordinal := range ordinals {
+		b.WriteString("  <Invoice><IssueDateTime>2019-06-12T17:05:43+02:00</IssueDateTime><InvOrdNum>" + ordinal + "</InvOrdNum>")
+		b.WriteString("<BusinUnitCode>bb123bb123</BusinUnitCode><TCRCode>cc123cc123</TCRCode><SoftCode>ss123ss123</SoftCode>")
+		b.WriteString("<TotPrice>10.00</TotPrice><Seller><IDNum>12345678</IDNum></Seller></Invoice>\n")
+	}
+	b.WriteString("</Invoices>\n")
+	return b.String()
+}
+
+func TestProcessingOrder(t *testing.T) {
+	ordinals := []string{"3", "1", "x", "2", "1", ""}
+	want := []int{1, 4, 3, 0, 2, 5}
+	tests := []struct {
+		name    string
+		content string
+		opts    ParseOptions
+	}{
+		{"attributes", testBundle(ordinals...), ParseOptions{}},
+		{"elements", testElementBundle(ordinals...), ParseOptions{FieldMode: FieldElements}},
+		{"auto", testElementBundle(ordinals...), ParseOptions{FieldMode: FieldAuto}},
+	}
+	for _, test := range tests {
+		invoices := readTestDocument(t, test.content).FindElements("//Invoice")
+		for run := 0; run < 3; run++ {
+			if order := processingOrder(invoices, test.opts); !reflect.DeepEqual(order, want) {
+				t.Errorf("%s: order is %v, want %v", test.name, order, want)
+			}
+		}
+	}
+}
+
+func TestStageOutOfOrder(t *testing.T) {
+	key, _ := newTestSigner(t)
+	signer := &recordingSigner{Signer: key}
+	doc := readTestDocument(t, testBundle("3", "1", "2"))
+	staged, err := Stage(signer, doc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byDigest := map[string]string{}
+	for i, result := range staged.Results {
+		if result.Index != i {
+			t.Errorf("result %d has index %d", i, result.Index)
+		}
+		byDigest[string(DigestForIIC(result.Fields))] = result.Fields[2]
+	}
+	signed := []string{}
+	for _, digest := range signer.digests {
+		signed = append(signed, byDigest[digest])
+	}
+	if want := []string{"1", "2", "3"}; !reflect.DeepEqual(signed, want) {
+		t.Errorf("invoices are signed in order %v, want %v", signed, want)
+	}
+
+	results, err := Commit(staged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, invoice := range doc.FindElements("//Invoice") {
+		if ordinal := invoice.SelectAttrValue("InvOrdNum", ""); ordinal != []string{"3", "1", "2"}[i] {
+			t.Errorf("invoice %d has ordinal %s, document order isn't preserved", i, ordinal)
+		}
+		if IIC := invoice.SelectAttrValue("IIC", ""); IIC != results[i].IIC {
+			t.Errorf("invoice %d has IIC %s, want %s", i, IIC, results[i].IIC)
+		}
+	}
+}
+
+func TestWriteIICFilteredElements(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	doc := readTestDocument(t, testElementBundle("1", "2", "3"))
+	store := NewMemorySeenStore()
+	params := &Params{ParseOptions: ParseOptions{FieldMode: FieldElements}, IICPlacement: IICElements, SeenStore: store}
+	results, err := WriteIICFiltered(signer, doc, func(fields InvoiceFields) bool { return fields.InvOrdNum != "2" }, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("%d invoices are processed, want 2", len(results))
+	}
+	for i, invoice := range doc.FindElements("//Invoice") {
+		element := invoice.SelectElement("IIC")
+		if i == 1 {
+			if element != nil {
+				t.Error("filtered out invoice is signed")
+			}
+			continue
+		}
+		if element == nil || invoice.SelectAttr("IIC") != nil {
+			t.Errorf("invoice %d doesn't have IIC element only", i)
+			continue
+		}
+		if result := results[i/2]; result.Status != InvoiceSigned || element.Text() != result.IIC {
+			t.Errorf("invoice %d has IIC %s, want %s", i, element.Text(), result.IIC)
+		}
+		if seen, _ := store.Seen(ReplayKey(results[i/2].Fields)); !seen {
+			t.Errorf("ordinal of invoice %d isn't recorded", i)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package iic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// NormalizeOptions selects normalizations applied by NormalizeDocument. TrimSpace trims surrounding whitespace
+// of the values of the IIC, CanonicalDateTime rewrites IssueDateTime with CanonicalizeDateTime and
+// NormalizeTotal rewrites the total with NormalizePrice. ParseOptions locate the values in the document
+type NormalizeOptions struct {
+	TrimSpace         bool
+	CanonicalDateTime bool
+	NormalizeTotal    bool
+	ParseOptions      ParseOptions
+}
+
+// NormalizeChange represents a value rewritten by NormalizeDocument. Invoice is index of the Invoice in doc
+type NormalizeChange struct {
+	Invoice int
+	Field   string
+	Old     string
+	New     string
+}
+
+// NormalizeDocument rewrites values of the IIC of every Invoice of doc according to opts, so the document is
+// ready for WriteIIC, and returns the changed values. Values missing in an invoice are left for WriteIIC to report
+func NormalizeDocument(doc *etree.Document, opts NormalizeOptions) ([]NormalizeChange, error) {
+	invoices := doc.FindElements("//Invoice")
+	if len(invoices) == 0 {
+		return nil, documentError(fmt.Errorf("can't find element %s", "//Invoice"))
+	}
+
+	changes := []NormalizeChange{}
+	for i, invoice := range invoices {
+		normalize := func(elem *etree.Element, name string, rewrite func(string) (string, error)) error {
+			old, set := fieldSetter(elem, name, opts.ParseOptions)
+			if set == nil {
+				return nil
+			}
+			value, err := rewrite(old)
+			if err != nil {
+				return documentError(fmt.Errorf("invoice %d: %v", i+1, err))
+			}
+			if value != old {
+				set(value)
+				changes = append(changes, NormalizeChange{Invoice: i, Field: name, Old: old, New: value})
+			}
+			return nil
+		}
+
+		names := []string{"IssueDateTime", "InvOrdNum", "BusinUnitCode", "TCRCode", "SoftCode", opts.ParseOptions.totalAttr()}
+		if opts.ParseOptions.DateTimeMode == DateTimeSeparate {
+			names = append(names[1:], "IssueDate", "IssueTime")
+		}
+		if opts.TrimSpace {
+			if seller, err := sellerOf(doc, invoice); err == nil {
+				if err := normalize(seller, opts.ParseOptions.SellerID.attr(), trimSpace); err != nil {
+					return nil, err
+				}
+			}
+			for _, name := range names {
+				if err := normalize(invoice, name, trimSpace); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if opts.CanonicalDateTime && opts.ParseOptions.DateTimeMode == DateTimeAttribute {
+			if err := normalize(invoice, "IssueDateTime", CanonicalizeDateTime); err != nil {
+				return nil, err
+			}
+		}
+		if opts.NormalizeTotal {
+			if err := normalize(invoice, opts.ParseOptions.totalAttr(), NormalizePrice); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+// trimSpace is strings.TrimSpace in form of a normalization
+func trimSpace(s string) (string, error) {
+	return strings.TrimSpace(s), nil
+}
+
+// fieldSetter returns value with given name of elem and a function replacing it, located according to
+// opts.FieldMode like fieldOf does. The function is nil if elem has no such value. Text of child elements
+// is returned untrimmed
+func fieldSetter(elem *etree.Element, name string, opts ParseOptions) (string, func(string)) {
+	if attr := elem.SelectAttr(name); attr != nil && opts.FieldMode != FieldElements {
+		return attr.Value, func(value string) { attr.Value = value }
+	}
+	if child := elem.SelectElement(name); child != nil && opts.FieldMode != FieldAttributes {
+		return child.Text(), child.SetText
+	}
+	return "", nil
+}
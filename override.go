@@ -0,0 +1,109 @@
+package iic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/beevik/etree"
+)
+
+// Overrides maps names of Invoice attributes to values applied to the document before IIC is generated,
+// e.g. codes which vary per deployment while the XML template is shared
+type Overrides map[string]string
+
+// LoadOverrides reads Overrides from JSON object of attribute names and values
+func LoadOverrides(file string) (Overrides, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	overrides := Overrides{}
+	if err := json.Unmarshal(buf, &overrides); err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+// applyOverrides applies params.Overrides, params.SoftCode, params.CanonicalDateTime and params.NormalizeTotal
+// to every Invoice of doc
+func applyOverrides(doc *etree.Document, params *Params) error {
+	if err := applyAttributes(doc, params); err != nil {
+		return err
+	}
+	if err := applySoftCode(doc, params); err != nil {
+		return err
+	}
+	return applyNormalization(doc, params)
+}
+
+// applyAttributes sets attributes of params.Overrides which are missing in the Invoice,
+// or every one of them if params.ForceOverrides is set
+func applyAttributes(doc *etree.Document, params *Params) error {
+	names := make([]string, 0, len(params.Overrides))
+	for name, value := range params.Overrides {
+		if err := validateField(name, value, params.ValidateOptions); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, invoice := range doc.FindElements("//Invoice") {
+		for _, name := range names {
+			if invoice.SelectAttr(name) != nil && !params.ForceOverrides {
+				continue
+			}
+			invoice.CreateAttr(name, params.Overrides[name])
+		}
+	}
+	return nil
+}
+
+// applySoftCode replaces SoftCode of every Invoice with params.SoftCode if it's set
+func applySoftCode(doc *etree.Document, params *Params) error {
+	if len(params.SoftCode) == 0 {
+		return nil
+	}
+	if err := ValidateSoftCode(params.SoftCode); err != nil {
+		return err
+	}
+
+	for i, invoice := range doc.FindElements("//Invoice") {
+		if current := invoice.SelectAttrValue("SoftCode", ""); len(current) > 0 && current != params.SoftCode {
+			params.warn(Warning{
+				Code:    WarningSoftCode,
+				Invoice: i + 1,
+				Message: fmt.Sprintf("overriding SoftCode %s with %s", current, params.SoftCode),
+			})
+		}
+		invoice.RemoveAttr("SoftCode")
+		invoice.CreateAttr("SoftCode", params.SoftCode)
+	}
+	return nil
+}
+
+// applyNormalization rewrites IssueDateTime of every Invoice in canonical form if params.CanonicalDateTime is set
+// and the total in bare numeric form if params.NormalizeTotal is set, see NormalizeDocument
+func applyNormalization(doc *etree.Document, params *Params) error {
+	if !params.CanonicalDateTime && !params.NormalizeTotal {
+		return nil
+	}
+	changes, err := NormalizeDocument(doc, NormalizeOptions{
+		CanonicalDateTime: params.CanonicalDateTime,
+		NormalizeTotal:    params.NormalizeTotal,
+		ParseOptions:      params.ParseOptions,
+	})
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		params.warn(Warning{
+			Code:    WarningNormalized,
+			Invoice: change.Invoice + 1,
+			Message: fmt.Sprintf("normalizing %s %q to %s", change.Field, change.Old, change.New),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package iic
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// DateTimeMode defines how IssueDateTime is retrieved from the document
+type DateTimeMode int
+
+const (
+	// DateTimeAttribute reads IssueDateTime attribute of the Invoice
+	DateTimeAttribute DateTimeMode = iota
+	// DateTimeSeparate combines IssueDate and IssueTime attributes of the Invoice, as found in legacy exports
+	DateTimeSeparate
+)
+
+// FieldMode defines how values of the IIC are represented in Invoice and Seller elements
+type FieldMode int
+
+const (
+	// FieldAttributes reads values from attributes, e.g. <Invoice InvOrdNum="1">, as in the official schema
+	FieldAttributes FieldMode = iota
+	// FieldElements reads values from text of child elements, e.g. <Invoice><InvOrdNum>1</InvOrdNum></Invoice>
+	FieldElements
+	// FieldAuto reads a value from the attribute if it's present, otherwise from text of the child element
+	FieldAuto
+)
+
+// SellerID defines which Seller attribute is used as TIN of the IIC. The IIC always uses the identifier
+// the seller is registered with: IDNum for sellers of IDType TIN, VATNumber for sellers identified by a VAT
+// number, e.g. on cross-border invoices
+type SellerID int
+
+const (
+	// SellerIDNum reads IDNum attribute of the Seller, which must be 8 or 13 digits
+	SellerIDNum SellerID = iota
+	// SellerVATNumber reads VATNumber attribute of the Seller, which must match VATNumberPattern
+	SellerVATNumber
+)
+
+// attr returns name of the Seller attribute used as TIN
+func (id SellerID) attr() string {
+	if id == SellerVATNumber {
+		return "VATNumber"
+	}
+	return "IDNum"
+}
+
+const (
+	issueDateLayout = "2006-01-02"
+	issueTimeLayout = "15:04:05Z07:00"
+
+	// canonicalDateTimeLayout is RFC 3339 with seconds precision and numeric offset, e.g. 2019-06-12T17:05:43+02:00
+	canonicalDateTimeLayout = "2006-01-02T15:04:05-07:00"
+	// noSecondsDateTimeLayout is RFC 3339 without seconds, e.g. 2019-06-12T17:05+02:00
+	noSecondsDateTimeLayout = "2006-01-02T15:04Z07:00"
+)
+
+// ParseOptions defines how values necessary for IIC generation are retrieved from the document.
+// TotalAttr names the Invoice attribute used as TotPrice of the IIC, empty means TotPrice. The IIC must
+// always use the total price including VAT, so set it only for documents carrying that total under another
+// name, never to TotPriceWoVAT or TotVATAmt. SellerID selects the Seller attribute used as TIN.
+// FieldMode selects whether values are read from attributes or child elements
+type ParseOptions struct {
+	DateTimeMode DateTimeMode
+	TotalAttr    string
+	SellerID     SellerID
+	FieldMode    FieldMode
+}
+
+// totalAttr returns name of the Invoice attribute used as TotPrice
+func (opts ParseOptions) totalAttr() string {
+	if len(opts.TotalAttr) == 0 {
+		return "TotPrice"
+	}
+	return opts.TotalAttr
+}
+
+// warnTotal warns when the configured total attribute isn't TotPrice, while the invoice has TotPrice,
+// which likely means that a wrong total is picked for the IIC
+func warnTotal(invoice *etree.Element, params *Params) {
+	name := params.ParseOptions.totalAttr()
+	if name != "TotPrice" && invoice.SelectAttr("TotPrice") != nil {
+		params.warnf(WarningTotal, "IIC uses %s as total, but the invoice has TotPrice which the IIC must use", name)
+	}
+}
+
+// detectedParseOptions read values of documents whose FieldMode isn't known, e.g. signed ones, from either
+// attributes or child elements
+var detectedParseOptions = ParseOptions{FieldMode: FieldAuto}
+
+// fieldOf retrieves value with given name from elem according to opts.FieldMode
+func fieldOf(elem *etree.Element, name string, opts ParseOptions) (string, error) {
+	switch opts.FieldMode {
+	case FieldAttributes:
+		return attributeOf(elem, name)
+	case FieldElements:
+		return textOf(elem, name)
+	case FieldAuto:
+		if elem.SelectAttr(name) != nil {
+			return attributeOf(elem, name)
+		}
+		return textOf(elem, name)
+	default:
+		return "", fmt.Errorf("unknown FieldMode %d", opts.FieldMode)
+	}
+}
+
+// textOf returns trimmed text of the child element with given name
+func textOf(elem *etree.Element, name string) (string, error) {
+	child := elem.SelectElement(name)
+	if child == nil {
+		return "", fmt.Errorf("can't find element %s", name)
+	}
+	return strings.TrimSpace(child.Text()), nil
+}
+
+// issueDateTime retrieves IssueDateTime according to opts.DateTimeMode
+func issueDateTime(invoice *etree.Element, opts ParseOptions) (string, error) {
+	switch opts.DateTimeMode {
+	case DateTimeAttribute:
+		return fieldOf(invoice, "IssueDateTime", opts)
+	case DateTimeSeparate:
+		date, err := fieldOf(invoice, "IssueDate", opts)
+		if err != nil {
+			return "", err
+		}
+		if _, err := time.Parse(issueDateLayout, date); err != nil {
+			return "", fmt.Errorf("IssueDate %s is not in format %s", date, issueDateLayout)
+		}
+		clock, err := fieldOf(invoice, "IssueTime", opts)
+		if err != nil {
+			return "", err
+		}
+		if _, err := time.Parse(issueTimeLayout, clock); err != nil {
+			return "", fmt.Errorf("IssueTime %s is not in format %s", clock, issueTimeLayout)
+		}
+		combined := date + "T" + clock
+		if _, err := time.Parse(time.RFC3339, combined); err != nil {
+			return "", fmt.Errorf("combined IssueDateTime %s is invalid: %v", combined, err)
+		}
+		return combined, nil
+	default:
+		return "", fmt.Errorf("unknown DateTimeMode %d", opts.DateTimeMode)
+	}
+}
+
+// CanonicalizeDateTime converts IssueDateTime in any accepted variant, with or without seconds and with either Z
+// or numeric offset, into the canonical form expected by the verifier: seconds precision, fractional seconds
+// dropped, and numeric offset, so Z becomes +00:00. The wall clock and offset are kept, e.g.
+// 2019-06-12T17:05Z becomes 2019-06-12T17:05:00+00:00
+func CanonicalizeDateTime(s string) (string, error) {
+	t, err := parseDateTime(s)
+	if err != nil {
+		return "", err
+	}
+	return t.Truncate(time.Second).Format(canonicalDateTimeLayout), nil
+}
+
+// parseDateTime parses IssueDateTime in any variant accepted by CanonicalizeDateTime
+func parseDateTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, noSecondsDateTimeLayout} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("IssueDateTime %q is not in RFC 3339 format", s)
+}
+
+// ParseFile retrieves values necessary for IIC generation from given file.
+// Orders of values are the same as for GenerateIIC parameters. Invoices after the first one aren't built,
+// so it's faster than parsing the document for consolidated files
+func ParseFile(file string, opts ParseOptions) ([7]string, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return [7]string{}, documentError(err)
+	}
+	doc, err := scanFirstInvoice(buf)
+	if err != nil {
+		return [7]string{}, documentError(err)
+	}
+	params, err := parse(doc, opts)
+	if err != nil {
+		return [7]string{}, documentError(err)
+	}
+	return params, nil
+}
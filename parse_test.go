@@ -0,0 +1,123 @@
+package iic
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/beevik/etree"
+)
+
+// testElementInvoice is testInvoice with values of the IIC written as child elements
+const testElementInvoice = `<?xml version="1.0" encoding="UTF-8"?>
+<RegisterInvoiceRequest xmlns="https://efi.tax.gov.me/fs/schema" Id="Request">
+  <Invoice TypeOfInv="CASH">
+    <IssueDateTime>2019-06-12T17:05:43+02:00</IssueDateTime>
+    <InvOrdNum>9952</InvOrdNum>
+    <BusinUnitCode>bb123bb123</BusinUnitCode>
+    <TCRCode>cc123cc123</TCRCode>
+    <SoftCode>ss123ss123</SoftCode>
+    <TotPrice> 99.01 </TotPrice>
+    <Seller IDType="TIN"><IDNum>12345678</IDNum></Seller>
+  </Invoice>
+</RegisterInvoiceRequest>
+`
+
+func TestParseFieldMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		mode    FieldMode
+		fails   bool
+	}{
+		{"attributes", testInvoice, FieldAttributes, false},
+		{"attributes auto", testInvoice, FieldAuto, false},
+		{"attributes as elements", testInvoice, FieldElements, true},
+		{"elements", testElementInvoice, FieldElements, false},
+		{"elements auto", testElementInvoice, FieldAuto, false},
+		{"elements as attributes", testElementInvoice, FieldAttributes, true},
+	}
+	for _, test := range tests {
+		fields, err := parse(readTestDocument(t, test.content), ParseOptions{FieldMode: test.mode})
+		switch {
+		case test.fails && err == nil:
+			t.Errorf("%s: parsed %v", test.name, fields)
+		case !test.fails && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case !test.fails && fields != testInvoiceFields:
+			t.Errorf("%s: parsed %v, want %v", test.name, fields, testInvoiceFields)
+		}
+	}
+}
+
+func TestSignedElementInvoice(t *testing.T) {
+	signer, certPEM := newTestSigner(t)
+	out := filepath.Join(t.TempDir(), "out.xml")
+	params := &Params{
+		Signer:       signer,
+		InFile:       writeTestFile(t, "in.xml", testElementInvoice),
+		OutFile:      out,
+		ParseOptions: ParseOptions{FieldMode: FieldElements},
+	}
+	if err := WriteIIC(params); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyIICFile(certPEM, out); err != nil {
+		t.Errorf("VerifyIICFile: %v", err)
+	}
+	signed, err := ReadSignedInvoices(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signed) != 1 || signed[0].Fields != testInvoiceFields {
+		t.Errorf("ReadSignedInvoices returned %v, want fields %v", signed, testInvoiceFields)
+	}
+	results := ReissueBatchDryRun(signer, []string{out}, t.TempDir(), nil)
+	if err := results[0].Err; err != nil {
+		t.Fatalf("ReissueBatchDryRun: %v", err)
+	}
+	if reissued := results[0].Invoices[0]; reissued.OldIIC != signed[0].IIC || reissued.IIC != signed[0].IIC {
+		t.Errorf("reissued IIC %s, old %s, want both %s", reissued.IIC, reissued.OldIIC, signed[0].IIC)
+	}
+}
+
+func TestWriteIICReadSettingsCDATA(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	// values wrapped in CDATA and a named entity only known to the exporting system
+	content := strings.Replace(testElementInvoice, "<InvOrdNum>9952</InvOrdNum>", "<InvOrdNum><![CDATA[9952]]></InvOrdNum>", 1)
+	content = strings.Replace(content, "<TotPrice> 99.01 </TotPrice>", "<TotPrice><![CDATA[99.01]]></TotPrice><Note>A&nbsp;B</Note>", 1)
+	content = strings.Replace(content, "<IDNum>12345678</IDNum>", "<IDNum><![CDATA[ 12345678 ]]></IDNum>", 1)
+	in := writeTestFile(t, "in.xml", content)
+	opts := ParseOptions{FieldMode: FieldElements}
+
+	strict := &Params{Signer: signer, InFile: in, OutFile: filepath.Join(t.TempDir(), "out.xml"), ParseOptions: opts}
+	if err := WriteIIC(strict); err == nil {
+		t.Error("strict reader accepts unknown entity")
+	}
+
+	out := filepath.Join(t.TempDir(), "out.xml")
+	params := &Params{Signer: signer, InFile: in, OutFile: out, ParseOptions: opts,
+		ReadSettings: &etree.ReadSettings{Entity: map[string]string{"nbsp": " "}}}
+	if err := WriteIIC(params); err != nil {
+		t.Fatal(err)
+	}
+	doc, _, err := readDocumentWith(out, params.ReadSettings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields, err := parse(doc, opts); err != nil || fields != testInvoiceFields {
+		t.Errorf("signed document has fields %v, %v, want %v", fields, err, testInvoiceFields)
+	}
+	IIC, _, err := ReadIIC(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _, err := generateIIC(signer, testInvoiceFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IIC != want {
+		t.Errorf("IIC is %s, want %s of the same values as attributes", IIC, want)
+	}
+}
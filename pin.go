@@ -0,0 +1,87 @@
+package iic
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/miekg/pkcs11"
+	"github.com/noshto/dsig/pkg/safenet"
+	"golang.org/x/term"
+)
+
+// PINEnvVar is the environment variable PIN is read from when it can't be prompted
+const PINEnvVar = "IIC_PIN"
+
+// PromptPIN sets config.UnlockPin reading it from the terminal without echo.
+// When stdin isn't a terminal, e.g. a pipe, PIN is read from PINEnvVar or the configured one is kept
+func PromptPIN(config *safenet.Config) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		if pin, ok := os.LookupEnv(PINEnvVar); ok {
+			config.UnlockPin = pin
+		}
+		if len(config.UnlockPin) == 0 {
+			return fmt.Errorf("can't prompt PIN: stdin isn't a terminal and neither %s nor UnlockPin is set", PINEnvVar)
+		}
+		return nil
+	}
+
+	fmt.Fprint(os.Stderr, "PIN: ")
+	pin, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+	config.UnlockPin = string(pin)
+	for i := range pin {
+		pin[i] = 0
+	}
+	return nil
+}
+
+// ClearPIN removes PIN from config, e.g. after SafeNet is initialized.
+// Note that SafeNet keeps its own copy for logins required by every signing operation
+func ClearPIN(config *safenet.Config) {
+	config.UnlockPin = ""
+}
+
+// PINFunc returns PIN right before SafeNet is initialized, e.g. fetched from a secrets manager,
+// so it isn't kept in the configuration
+type PINFunc func() (string, error)
+
+// configWithPIN returns copy of config with UnlockPin obtained from pin
+func configWithPIN(config *safenet.Config, pin PINFunc) (*safenet.Config, error) {
+	value, err := pin()
+	if err != nil {
+		return nil, signerError(fmt.Errorf("can't obtain PIN for SafeNet initialization: %v", err))
+	}
+	withPIN := *config
+	withPIN.UnlockPin = value
+	return &withPIN, nil
+}
+
+// badPINCodes lists PKCS#11 return values meaning that the PIN is rejected
+var badPINCodes = []pkcs11.Error{
+	pkcs11.CKR_PIN_INCORRECT,
+	pkcs11.CKR_PIN_INVALID,
+	pkcs11.CKR_PIN_LEN_RANGE,
+	pkcs11.CKR_PIN_EXPIRED,
+}
+
+// pinErrorClass returns ErrTokenLocked or ErrBadPIN if err is a PKCS#11 error rejecting the PIN, otherwise nil
+func pinErrorClass(err error) error {
+	var code pkcs11.Error
+	if !errors.As(err, &code) {
+		return nil
+	}
+	if code == pkcs11.CKR_PIN_LOCKED {
+		return ErrTokenLocked
+	}
+	for _, bad := range badPINCodes {
+		if code == bad {
+			return ErrBadPIN
+		}
+	}
+	return nil
+}
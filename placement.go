@@ -0,0 +1,81 @@
+package iic
+
+import (
+	"fmt"
+
+	"github.com/beevik/etree"
+)
+
+// IICPlacement defines how IIC and IICSignature are written into the Invoice
+type IICPlacement int
+
+const (
+	// IICAttributes writes IIC and IICSignature as attributes of the Invoice, as in the official schema
+	IICAttributes IICPlacement = iota
+	// IICElements writes IIC and IICSignature as child elements of the Invoice in its namespace,
+	// as expected by some schema variants
+	IICElements
+)
+
+// String returns human readable name of the placement
+func (p IICPlacement) String() string {
+	switch p {
+	case IICAttributes:
+		return "attributes"
+	case IICElements:
+		return "elements"
+	default:
+		return "unknown"
+	}
+}
+
+// SetIICElements is the same as SetIIC, but writes IIC and IICSignature as child elements of the invoice
+// with its namespace prefix, replacing existing ones
+func SetIICElements(invoice *etree.Element, iic string, iicSignature string) {
+	for _, name := range []string{"IIC", "IICSignature"} {
+		for _, child := range invoice.SelectElements(name) {
+			invoice.RemoveChild(child)
+		}
+		invoice.RemoveAttr(name)
+	}
+	invoice.CreateElement(qualified(invoice, "IIC")).SetText(iic)
+	invoice.CreateElement(qualified(invoice, "IICSignature")).SetText(iicSignature)
+}
+
+// qualified returns name prefixed with namespace prefix of elem, if it has one
+func qualified(elem *etree.Element, name string) string {
+	if len(elem.Space) == 0 {
+		return name
+	}
+	return elem.Space + ":" + name
+}
+
+// setIICAs writes IIC and IICSignature into the invoice according to placement
+func setIICAs(invoice *etree.Element, iic string, iicSignature string, placement IICPlacement) {
+	if placement == IICElements {
+		SetIICElements(invoice, iic, iicSignature)
+		return
+	}
+	SetIIC(invoice, iic, iicSignature)
+}
+
+// checkPlacement fails if an Invoice of doc already has IIC in the other form than placement, which means
+// that placement doesn't match the schema of the document
+func checkPlacement(doc *etree.Document, placement IICPlacement) error {
+	for i, invoice := range doc.FindElements("//Invoice") {
+		hasAttr := invoice.SelectAttr("IIC") != nil
+		hasElem := invoice.SelectElement("IIC") != nil
+		if placement == IICAttributes && hasElem || placement == IICElements && hasAttr && !hasElem {
+			return fmt.Errorf("invoice %d has IIC in other form than IICPlacement %s of params", i+1, placement)
+		}
+	}
+	return nil
+}
+
+// placementOf returns placement of existing IIC of the invoice
+func placementOf(invoice *etree.Element) IICPlacement {
+	if invoice.SelectElement("IIC") != nil {
+		return IICElements
+	}
+	return IICAttributes
+}
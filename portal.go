@@ -0,0 +1,47 @@
+package iic
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// PortalCSVColumns are columns of the bulk import CSV written by ExportPortalCSV, in order: values of the IIC
+// named as in the invoice schema, followed by IIC and IICSignature
+var PortalCSVColumns = []string{"TIN", "IssueDateTime", "InvOrdNum", "BusinUnitCode", "TCRCode", "SoftCode", "TotPrice", "IIC", "IICSignature"}
+
+// ExportPortalCSV writes results as the bulk import CSV of the tax portal: a header row of PortalCSVColumns and
+// a row per result. Rows are separated by CRLF, and a value is quoted, with quotes doubled, only if it contains
+// a comma, quote, line break or leading space, see RFC 4180. Every column but TCRCode is required, empty TCRCode
+// stands for invoices issued without a cash register. Results are checked before anything is written
+func ExportPortalCSV(results []IICResult, w io.Writer) error {
+	errs := []error{}
+	for i, result := range results {
+		for j, value := range portalRow(result) {
+			if len(value) == 0 && PortalCSVColumns[j] != "TCRCode" {
+				errs = append(errs, fmt.Errorf("result %d: %s is empty", i+1, PortalCSVColumns[j]))
+			}
+		}
+	}
+	if err := validationError(errs); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = true
+	if err := writer.Write(PortalCSVColumns); err != nil {
+		return err
+	}
+	for _, result := range results {
+		if err := writer.Write(portalRow(result)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// portalRow returns values of result in the order of PortalCSVColumns
+func portalRow(result IICResult) []string {
+	return append(result.Fields[:], result.IIC, result.IICSignature)
+}
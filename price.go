@@ -0,0 +1,100 @@
+package iic
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RoundPrice formats amount with two decimals the way the authority does: half-up, i.e. ties are rounded
+// away from zero (2.345 becomes 2.35, -2.345 becomes -2.35). Rounding is applied to the shortest decimal
+// representation of amount, so binary floating point artifacts don't turn ties into round-downs.
+// NaN and infinities have no decimal form, they're returned as "NaN", "+Inf" and "-Inf", which aren't
+// a valid TotPrice, so the IIC can't be generated from them
+func RoundPrice(amount float64) string {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return strconv.FormatFloat(amount, 'f', -1, 64)
+	}
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(amount, 'f', -1, 64))
+	return roundRat(r)
+}
+
+// roundRat rounds r half-up to two decimals
+func roundRat(r *big.Rat) string {
+	negative := r.Sign() < 0
+	cents := new(big.Rat).Abs(r)
+	cents.Mul(cents, big.NewRat(100, 1))
+	cents.Add(cents, big.NewRat(1, 2))
+	rounded := new(big.Int).Quo(cents.Num(), cents.Denom())
+
+	if negative && rounded.Sign() != 0 {
+		rounded.Neg(rounded)
+	}
+	return new(big.Rat).SetFrac(rounded, big.NewInt(100)).FloatString(2)
+}
+
+// currencyRegexp matches currency symbol or code put before or after an amount
+var currencyRegexp = regexp.MustCompile(`(?i)^\s*(€|eur)?\s*(.*?)\s*(€|eur)?\s*$`)
+
+// bareNumberRegexp matches amount in the form used by the IIC
+var bareNumberRegexp = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// groupedRegexp matches digits grouped by three with given separator, e.g. 1,234,567
+var groupedRegexp = map[string]*regexp.Regexp{
+	",": regexp.MustCompile(`^-?[1-9][0-9]{0,2}(,[0-9]{3})+$`),
+	".": regexp.MustCompile(`^-?[1-9][0-9]{0,2}(\.[0-9]{3})+$`),
+}
+
+// NormalizePrice converts amount written with euro symbol or code and thousands separators, e.g. "€1,234.50",
+// "1.234,50 EUR" or "1 234.50", into the bare numeric form used by the IIC, e.g. "1234.50", rounded half-up
+// to two decimals like RoundPrice does. The rightmost of mixed separators is decimal. A single comma or dot
+// followed by three digits, e.g. "1,234" or "1.234", is reported as ambiguous, as it may be either decimal
+// or thousands separator
+func NormalizePrice(amount string) (string, error) {
+	match := currencyRegexp.FindStringSubmatch(amount)
+	if len(match[1]) > 0 && len(match[3]) > 0 {
+		return "", fmt.Errorf("TotPrice %q has currency on both sides", amount)
+	}
+	number := strings.Replace(match[2], " ", "", -1)
+
+	integer, fraction := number, ""
+	comma, dot := strings.LastIndex(number, ","), strings.LastIndex(number, ".")
+	switch {
+	case comma >= 0 && dot >= 0:
+		decimal := comma
+		if dot > comma {
+			decimal = dot
+		}
+		integer, fraction = number[:decimal], number[decimal+1:]
+	case strings.Count(number, ",") == 1 && groupedRegexp[","].MatchString(number):
+		return "", fmt.Errorf("TotPrice %q is ambiguous, comma may be decimal or thousands separator", amount)
+	case strings.Count(number, ".") == 1 && groupedRegexp["."].MatchString(number):
+		return "", fmt.Errorf("TotPrice %q is ambiguous, dot may be decimal or thousands separator", amount)
+	case comma >= 0 && strings.Count(number, ",") == 1:
+		integer, fraction = number[:comma], number[comma+1:]
+	case dot >= 0 && strings.Count(number, ".") == 1:
+		integer, fraction = number[:dot], number[dot+1:]
+	}
+
+	for separator, grouped := range groupedRegexp {
+		if strings.Contains(integer, separator) {
+			if !grouped.MatchString(integer) {
+				return "", fmt.Errorf("TotPrice %q has misplaced separators", amount)
+			}
+			integer = strings.Replace(integer, separator, "", -1)
+		}
+	}
+
+	normalized := integer
+	if len(fraction) > 0 {
+		normalized += "." + fraction
+	}
+	if !bareNumberRegexp.MatchString(normalized) {
+		return "", fmt.Errorf("TotPrice %q is not a number", amount)
+	}
+	r, _ := new(big.Rat).SetString(normalized)
+	return roundRat(r), nil
+}
@@ -0,0 +1,98 @@
+package iic
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRoundPrice(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   string
+	}{
+		{0, "0.00"},
+		{0.005, "0.01"},
+		{0.004, "0.00"},
+		{2.345, "2.35"},
+		{2.675, "2.68"},
+		{1.005, "1.01"},
+		{-2.345, "-2.35"},
+		{-0.005, "-0.01"},
+		{-0.004, "0.00"},
+		{1234.5, "1234.50"},
+		{99.999, "100.00"},
+	}
+	for _, test := range tests {
+		if got := RoundPrice(test.amount); got != test.want {
+			t.Errorf("RoundPrice(%v) = %s, want %s", test.amount, got, test.want)
+		}
+	}
+}
+
+func TestRoundPriceNotFinite(t *testing.T) {
+	for amount, want := range map[float64]string{math.Inf(1): "+Inf", math.Inf(-1): "-Inf"} {
+		if got := RoundPrice(amount); got != want {
+			t.Errorf("RoundPrice(%v) = %s, want %s", amount, got, want)
+		}
+	}
+	if got := RoundPrice(math.NaN()); got != "NaN" {
+		t.Errorf("RoundPrice(NaN) = %s, want NaN", got)
+	}
+	for _, amount := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if err := validateTotPrice(RoundPrice(amount)); err == nil {
+			t.Errorf("TotPrice %s is valid", RoundPrice(amount))
+		}
+	}
+}
+
+func TestNormalizePrice(t *testing.T) {
+	tests := []struct {
+		amount string
+		want   string
+	}{
+		{"1234.50", "1234.50"},
+		{"€1,234.50", "1234.50"},
+		{"1234.50 EUR", "1234.50"},
+		{"eur 12.5", "12.50"},
+		{"1.234,50 EUR", "1234.50"},
+		{"1 234.50", "1234.50"},
+		{"1,234,567.891", "1234567.89"},
+		{"1.234.567", "1234567.00"},
+		{"12,5", "12.50"},
+		{"0,125", "0.13"},
+		{"1234,567", "1234.57"},
+		{"-2.3450", "-2.35"},
+		{"-0.005", "-0.01"},
+		{"100", "100.00"},
+	}
+	for _, test := range tests {
+		got, err := NormalizePrice(test.amount)
+		if err != nil {
+			t.Errorf("NormalizePrice(%q): %v", test.amount, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("NormalizePrice(%q) = %s, want %s", test.amount, got, test.want)
+		}
+	}
+}
+
+func TestNormalizePriceMalformed(t *testing.T) {
+	for _, amount := range []string{
+		"1,234",
+		"1.234",
+		"€1,234",
+		"1.234 EUR",
+		"€12.00 EUR",
+		"12,34,56",
+		"1,23.45",
+		"12a.00",
+		"",
+		"EUR",
+		"1..2",
+	} {
+		if got, err := NormalizePrice(amount); err == nil {
+			t.Errorf("NormalizePrice(%q) = %s, want error", amount, got)
+		}
+	}
+}
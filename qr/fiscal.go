@@ -0,0 +1,25 @@
+package qr
+
+import (
+	"bytes"
+
+	"github.com/beevik/etree"
+	"github.com/noshto/iic"
+)
+
+// FiscalSize is the size in pixels of the QR code rendered by FiscalizeInvoice
+const FiscalSize = 256
+
+// FiscalizeInvoice is the same as iic.FiscalizeInvoice, but renders the verification QR code as well
+func FiscalizeInvoice(signer iic.Signer, doc *etree.Document) (*iic.FiscalResult, error) {
+	result, err := iic.FiscalizeInvoice(signer, doc)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.Buffer{}
+	if err := WriteQRCode(&buf, result.VerificationURL, FiscalSize); err != nil {
+		return nil, err
+	}
+	result.QR = buf.Bytes()
+	return result, nil
+}
@@ -0,0 +1,43 @@
+// Package qr renders verification QR codes of fiscalized invoices, as images or a printable PDF sheet.
+// It's kept apart from the iic package, so users who don't print receipts don't depend on a QR encoder
+package qr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// WriteQRCode writes QR code of content, e.g. iic.VerificationURL, as PNG image of size x size pixels
+func WriteQRCode(w io.Writer, content string, size int) error {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return err
+	}
+	return code.Write(size, w)
+}
+
+// WriteQRCodeSVG writes QR code of content as SVG image of size x size units
+func WriteQRCodeSVG(w io.Writer, content string, size int) error {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return err
+	}
+	bitmap := code.Bitmap()
+	modules := len(bitmap)
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, modules, modules)
+	fmt.Fprintf(out, `<rect width="%d" height="%d" fill="#fff"/>`, modules, modules)
+	for y, row := range bitmap {
+		for x, black := range row {
+			if black {
+				fmt.Fprintf(out, `<rect x="%d" y="%d" width="1" height="1"/>`, x, y)
+			}
+		}
+	}
+	fmt.Fprintln(out, `</svg>`)
+	return out.Flush()
+}
@@ -0,0 +1,148 @@
+package qr
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/noshto/iic"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// SheetLayout defines how many QR codes are placed on a page of QRSheet
+type SheetLayout struct {
+	Columns int
+	Rows    int
+}
+
+// DefaultSheetLayout places 3 x 4 QR codes per A4 page
+var DefaultSheetLayout = SheetLayout{Columns: 3, Rows: 4}
+
+const (
+	// pageWidth and pageHeight are A4 size in points
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	// sheetMargin is the margin of the page, captionHeight is the space for a caption under a QR code
+	sheetMargin   = 36.0
+	captionHeight = 14.0
+	captionSize   = 9
+)
+
+// QRSheet writes PDF with verification QR code of every invoice, captioned with its ordinal and total,
+// placed according to DefaultSheetLayout
+func QRSheet(invoices []iic.SignedInvoice, w io.Writer) error {
+	return QRSheetWithLayout(invoices, w, DefaultSheetLayout)
+}
+
+// QRSheetWithLayout is the same as QRSheet, but places QR codes according to given layout
+func QRSheetWithLayout(invoices []iic.SignedInvoice, w io.Writer, layout SheetLayout) error {
+	if layout.Columns < 1 || layout.Rows < 1 {
+		return fmt.Errorf("layout must have at least one column and row, got %d x %d", layout.Columns, layout.Rows)
+	}
+	perPage := layout.Columns * layout.Rows
+	pages := []string{}
+	for start := 0; start < len(invoices) || start == 0; start += perPage {
+		end := start + perPage
+		if end > len(invoices) {
+			end = len(invoices)
+		}
+		content, err := pageContent(invoices[start:end], layout)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, content)
+	}
+	return writePDF(w, pages)
+}
+
+// pageContent returns content stream drawing QR codes of invoices of a single page
+func pageContent(invoices []iic.SignedInvoice, layout SheetLayout) (string, error) {
+	cellWidth := (pageWidth - 2*sheetMargin) / float64(layout.Columns)
+	cellHeight := (pageHeight - 2*sheetMargin) / float64(layout.Rows)
+	size := cellWidth
+	if cellHeight-captionHeight < size {
+		size = cellHeight - captionHeight
+	}
+	size *= 0.9
+
+	content := strings.Builder{}
+	for i, invoice := range invoices {
+		code, err := qrcode.New(invoice.VerificationURL(), qrcode.Medium)
+		if err != nil {
+			return "", err
+		}
+		column, row := i%layout.Columns, i/layout.Columns
+		left := sheetMargin + float64(column)*cellWidth + (cellWidth-size)/2
+		top := pageHeight - sheetMargin - float64(row)*cellHeight
+		drawBitmap(&content, code.Bitmap(), left, top, size)
+
+		caption := fmt.Sprintf("InvOrdNum %s  TotPrice %s", invoice.Fields[2], invoice.Fields[6])
+		fmt.Fprintf(&content, "BT /F1 %d Tf %.2f %.2f Td (%s) Tj ET\n", captionSize, left, top-size-captionHeight+4, escapePDF(caption))
+	}
+	return content.String(), nil
+}
+
+// drawBitmap draws black modules of bitmap as filled rectangles in a square of given size with top left
+// corner at left, top. Consecutive modules of a row are merged into a single rectangle
+func drawBitmap(content *strings.Builder, bitmap [][]bool, left float64, top float64, size float64) {
+	module := size / float64(len(bitmap))
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(content, "%.2f %.2f %.2f %.2f re\n", left+float64(start)*module, top-float64(y+1)*module, float64(x-start)*module, module)
+		}
+	}
+	content.WriteString("f\n")
+}
+
+// escapePDF escapes characters with special meaning in PDF string literals
+func escapePDF(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`).Replace(s)
+}
+
+// writePDF writes PDF document of pages with given content streams, using Helvetica for text
+func writePDF(w io.Writer, pages []string) error {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // pages, filled in below
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	kids := []string{}
+	for _, content := range pages {
+		pageRef := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageRef))
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, pageRef+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	buf := bytes.Buffer{}
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	out := bufio.NewWriter(w)
+	if _, err := out.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return out.Flush()
+}
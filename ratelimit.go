@@ -0,0 +1,58 @@
+package iic
+
+import (
+	"context"
+	"crypto/x509"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimitedSigner paces signing operations of the wrapped Signer, e.g. for network HSMs with operation quotas
+type RateLimitedSigner struct {
+	inner   Signer
+	limiter *rate.Limiter
+}
+
+// NewRateLimitedSigner wraps inner signer allowing at most rps signing operations per second.
+// Non-positive rps disables the limit
+func NewRateLimitedSigner(inner Signer, rps int) *RateLimitedSigner {
+	return &RateLimitedSigner{
+		inner:   inner,
+		limiter: rate.NewLimiter(limitOf(rps), 1),
+	}
+}
+
+// SetLimit changes allowed number of signing operations per second
+func (s *RateLimitedSigner) SetLimit(rps int) {
+	s.limiter.SetLimit(limitOf(rps))
+}
+
+// SignPKCS1v15 waits for the limiter and signs data with the wrapped signer
+func (s *RateLimitedSigner) SignPKCS1v15(data []byte) ([]byte, error) {
+	return s.SignPKCS1v15Context(context.Background(), data)
+}
+
+// SignPKCS1v15Context is the same as SignPKCS1v15, but stops waiting for the limiter when ctx is done
+func (s *RateLimitedSigner) SignPKCS1v15Context(ctx context.Context, data []byte) ([]byte, error) {
+	if err := s.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return s.inner.SignPKCS1v15(data)
+}
+
+// GetCertificate returns certificate of the wrapped signer
+func (s *RateLimitedSigner) GetCertificate() (x509.Certificate, error) {
+	cert, err := certificateOf(s.inner)
+	if err != nil {
+		return x509.Certificate{}, err
+	}
+	return *cert, nil
+}
+
+// limitOf converts operations per second into rate.Limit
+func limitOf(rps int) rate.Limit {
+	if rps <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(rps)
+}
@@ -0,0 +1,98 @@
+package iic
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/beevik/etree"
+)
+
+// DefaultReceiptWidth is the line width of 58 mm thermal printers, in characters
+const DefaultReceiptWidth = 32
+
+// minReceiptWidth is the narrowest line fitting a label and a value
+const minReceiptWidth = 16
+
+// ReceiptOptions adjusts RenderReceiptWithOptions. Width is the line width in characters,
+// DefaultReceiptWidth if it's zero, e.g. 48 for 80 mm printers
+type ReceiptOptions struct {
+	Width        int
+	ParseOptions ParseOptions
+}
+
+// RenderReceipt is the same as RenderReceiptWithOptions with default options
+func RenderReceipt(doc *etree.Document, iic string) (string, error) {
+	return RenderReceiptWithOptions(doc, iic, ReceiptOptions{})
+}
+
+// RenderReceiptWithOptions returns plain text fiscal receipt of the first Invoice of doc signed with given IIC,
+// for a thermal printer: seller, business unit and cash register, ordinal, issue time, total, IIC and
+// VerificationURL. Values too long for a line are wrapped
+func RenderReceiptWithOptions(doc *etree.Document, iic string, opts ReceiptOptions) (string, error) {
+	fields, err := parse(doc, opts.ParseOptions)
+	if err != nil {
+		return "", documentError(err)
+	}
+	width := opts.Width
+	if width == 0 {
+		width = DefaultReceiptWidth
+	}
+	if width < minReceiptWidth {
+		return "", fmt.Errorf("receipt width %d is less than %d", width, minReceiptWidth)
+	}
+
+	issued := fields[1]
+	if t, err := parseDateTime(fields[1]); err == nil {
+		issued = t.Format("02.01.2006 15:04:05")
+	}
+
+	builder := strings.Builder{}
+	rule := strings.Repeat("-", width)
+	writeCentered(&builder, "FISCAL RECEIPT", width)
+	builder.WriteString(rule + "\n")
+	writeReceiptLine(&builder, "TIN", fields[0], width)
+	writeReceiptLine(&builder, "Business unit", fields[3], width)
+	if len(fields[4]) > 0 {
+		writeReceiptLine(&builder, "Cash register", fields[4], width)
+	}
+	writeReceiptLine(&builder, "Invoice", fields[2], width)
+	writeReceiptLine(&builder, "Issued", issued, width)
+	writeReceiptLine(&builder, "Total", fields[6], width)
+	builder.WriteString(rule + "\n")
+	builder.WriteString("IIC\n")
+	writeWrapped(&builder, iic, width)
+	builder.WriteString("Verify at\n")
+	writeWrapped(&builder, VerificationURL(fields, iic), width)
+	return builder.String(), nil
+}
+
+// writeCentered writes s centered in a line of given width
+func writeCentered(builder *strings.Builder, s string, width int) {
+	if pad := (width - utf8.RuneCountInString(s)) / 2; pad > 0 {
+		builder.WriteString(strings.Repeat(" ", pad))
+	}
+	builder.WriteString(s + "\n")
+}
+
+// writeReceiptLine writes label and value aligned to the right in a line of given width,
+// or value wrapped under the label if they don't fit
+func writeReceiptLine(builder *strings.Builder, label string, value string, width int) {
+	gap := width - utf8.RuneCountInString(label) - utf8.RuneCountInString(value)
+	if gap < 1 {
+		builder.WriteString(label + "\n")
+		writeWrapped(builder, value, width)
+		return
+	}
+	builder.WriteString(label + strings.Repeat(" ", gap) + value + "\n")
+}
+
+// writeWrapped writes s broken into lines of at most width characters
+func writeWrapped(builder *strings.Builder, s string, width int) {
+	runes := []rune(s)
+	for len(runes) > width {
+		builder.WriteString(string(runes[:width]) + "\n")
+		runes = runes[width:]
+	}
+	builder.WriteString(string(runes) + "\n")
+}
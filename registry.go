@@ -0,0 +1,54 @@
+package iic
+
+import (
+	"fmt"
+	"sync"
+)
+
+// CertRegistry maps TINs of taxpayers to signers holding their certificates, e.g. for service bureaus
+// signing on behalf of many taxpayers
+type CertRegistry struct {
+	mu      sync.RWMutex
+	signers map[string]Signer
+}
+
+// NewCertRegistry creates empty CertRegistry
+func NewCertRegistry() *CertRegistry {
+	return &CertRegistry{signers: map[string]Signer{}}
+}
+
+// Register adds signer for the TIN found in its certificate, if the certificate allows signing, see CheckKeyUsage.
+// Signer must be a CertificateSource
+func (r *CertRegistry) Register(signer Signer) error {
+	cert, err := certificateOf(signer)
+	if err != nil {
+		return err
+	}
+	if err := CheckKeyUsage(cert); err != nil {
+		return err
+	}
+	tin, err := TINFromCertificate(cert)
+	if err != nil {
+		return err
+	}
+	r.RegisterTIN(tin, signer)
+	return nil
+}
+
+// RegisterTIN adds signer for given TIN, replacing previously registered one
+func (r *CertRegistry) RegisterTIN(tin string, signer Signer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.signers[tin] = signer
+}
+
+// SignerForTIN returns signer registered for given TIN
+func (r *CertRegistry) SignerForTIN(tin string) (Signer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	signer, ok := r.signers[tin]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTIN, tin)
+	}
+	return signer, nil
+}
@@ -0,0 +1,134 @@
+package iic
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+
+	"github.com/beevik/etree"
+)
+
+// ReissueOptions adjusts ReissueBatchWithOptions. Previous holds certificates used before the renewal,
+// to identify which of them made the replaced IICs. DryRun computes results without writing files
+type ReissueOptions struct {
+	Previous *TrustStore
+	DryRun   bool
+}
+
+// ReissueResult represents outcome of re-signing a file of ReissueBatch. Thumbprint is CertificateThumbprint
+// of the signer's certificate. Either every invoice of the file is re-signed or, on Err, none
+type ReissueResult struct {
+	InFile     string
+	OutFile    string
+	Thumbprint string
+	Invoices   []ReissuedInvoice
+	Err        error
+}
+
+// ReissuedInvoice represents an invoice re-signed by ReissueBatch. OldIIC is the replaced IIC, empty if the
+// invoice was unsigned, and OldThumbprint is the thumbprint of the previous certificate which made it,
+// empty if it isn't found in ReissueOptions.Previous
+type ReissuedInvoice struct {
+	Index         int
+	OldIIC        string
+	OldThumbprint string
+	IIC           string
+	IICSignature  string
+}
+
+// ReissueBatch is the same as ReissueBatchWithOptions with default options
+func ReissueBatch(signer Signer, files []string, outDir string) []ReissueResult {
+	return ReissueBatchWithOptions(signer, files, outDir, ReissueOptions{})
+}
+
+// ReissueBatchDryRun is the same as ReissueBatchWithOptions with ReissueOptions.DryRun set
+func ReissueBatchDryRun(signer Signer, files []string, outDir string, previous *TrustStore) []ReissueResult {
+	return ReissueBatchWithOptions(signer, files, outDir, ReissueOptions{Previous: previous, DryRun: true})
+}
+
+// ReissueBatchWithOptions re-signs every Invoice of files with signer after a certificate renewal, replacing
+// existing IICs, and saves the results into outDir under their base names. Values are read from attributes
+// or child elements, whichever the invoice has, see FieldAuto. Signer must be a CertificateSource,
+// so results are tagged with thumbprint of the new certificate
+func ReissueBatchWithOptions(signer Signer, files []string, outDir string, opts ReissueOptions) []ReissueResult {
+	results := make([]ReissueResult, len(files))
+	cert, err := certificateOf(signer)
+	for i, file := range files {
+		results[i] = ReissueResult{InFile: file, OutFile: filepath.Join(outDir, filepath.Base(file))}
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Thumbprint = CertificateThumbprint(cert)
+		results[i].Invoices, results[i].Err = reissueFile(signer, results[i].InFile, results[i].OutFile, opts)
+	}
+	return results
+}
+
+// reissueFile re-signs every Invoice of inFile and saves the result to outFile unless opts.DryRun is set
+func reissueFile(signer Signer, inFile string, outFile string, opts ReissueOptions) ([]ReissuedInvoice, error) {
+	doc, hasBOM, err := readDocument(inFile)
+	if err != nil {
+		return nil, documentError(err)
+	}
+	invoices := doc.FindElements("//Invoice")
+	if len(invoices) == 0 {
+		return nil, documentError(fmt.Errorf("can't find element %s", "//Invoice"))
+	}
+
+	reissued := make([]ReissuedInvoice, len(invoices))
+	for i, invoice := range invoices {
+		if reissued[i], err = reissueInvoice(signer, doc, invoice, opts); err != nil {
+			return nil, fmt.Errorf("invoice %d: %w", i+1, err)
+		}
+		reissued[i].Index = i
+	}
+	if opts.DryRun {
+		return reissued, nil
+	}
+
+	for i, invoice := range invoices {
+		setIICAs(invoice, reissued[i].IIC, reissued[i].IICSignature, placementOf(invoice))
+	}
+	params := &Params{}
+	formatDocument(doc, params.OutputStyle)
+	if err := writeDocument(doc, outFile, hasBOM, params); err != nil {
+		return nil, err
+	}
+	return reissued, nil
+}
+
+// reissueInvoice generates new IIC of the invoice of doc and identifies the previous certificate of its old IIC
+func reissueInvoice(signer Signer, doc *etree.Document, invoice *etree.Element, opts ReissueOptions) (ReissuedInvoice, error) {
+	parsed, err := parseInvoice(doc, invoice, detectedParseOptions)
+	if err != nil {
+		return ReissuedInvoice{}, documentError(err)
+	}
+	result := ReissuedInvoice{}
+	if oldIIC, oldIICSignature, err := iicOf(invoice); err == nil {
+		result.OldIIC = oldIIC
+		result.OldThumbprint = previousThumbprint(opts.Previous, parsed, oldIICSignature)
+	}
+	if result.IIC, result.IICSignature, err = generateIIC(signer, parsed); err != nil {
+		return ReissuedInvoice{}, err
+	}
+	return result, nil
+}
+
+// previousThumbprint returns thumbprint of the certificate of store whose key made iicSignature of params,
+// or empty string if there is none
+func previousThumbprint(store *TrustStore, params [7]string, iicSignature string) string {
+	if store == nil {
+		return ""
+	}
+	signature, err := hex.DecodeString(iicSignature)
+	if err != nil {
+		return ""
+	}
+	for _, cert := range store.CertificatesForTIN(params[0]) {
+		if verifySignature(cert.PublicKey, DigestForIIC(params), signature) == nil {
+			return CertificateThumbprint(cert)
+		}
+	}
+	return ""
+}
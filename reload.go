@@ -0,0 +1,208 @@
+package iic
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/noshto/dsig/pkg/safenet"
+)
+
+// SignerLoader creates a new signer, e.g. from freshly read configuration
+type SignerLoader func() (Signer, error)
+
+// SafeNetLoader returns SignerLoader which reads SafeNet configuration from JSON configFile and initializes
+// SafeNet with it, see Params.InitTimeout for timeout
+func SafeNetLoader(configFile string, timeout time.Duration) SignerLoader {
+	return func() (Signer, error) {
+		buf, err := ioutil.ReadFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		config := &safenet.Config{}
+		if err := json.Unmarshal(buf, config); err != nil {
+			return nil, fmt.Errorf("%s: %v", configFile, err)
+		}
+		return initializeSafeNet(config, timeout, nil)
+	}
+}
+
+// maxReloadBackoff caps the delay between retries of a failing reload by the watcher
+const maxReloadBackoff = 5 * time.Minute
+
+// ReloadableSigner delegates to a signer created by SignerLoader and replaces it when the watched file
+// changes, e.g. when the certificate is rotated. The replaced signer is finalized after in-flight
+// signing operations complete, if it has Finalize method like *safenet.SafeNet, and before the new one is
+// loaded, since PKCS#11 can't be initialized twice in a process. Until a failed reload is retried
+// successfully signing fails with ErrSignerNotReady. The watcher retries with exponential backoff
+type ReloadableSigner struct {
+	file     string
+	load     SignerLoader
+	interval time.Duration
+
+	mu       sync.RWMutex
+	signer   Signer
+	modTime  time.Time
+	err      error
+	failures int
+	retryAt  time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReloadableSigner loads the signer and checks modification time of file every interval, reloading
+// the signer when it changes. Non-positive interval disables watching, use Reload instead
+func NewReloadableSigner(file string, load SignerLoader, interval time.Duration) (*ReloadableSigner, error) {
+	s := &ReloadableSigner{
+		file:     file,
+		load:     load,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		close(s.done)
+		return s, nil
+	}
+	go s.watch(interval)
+	return s, nil
+}
+
+// watch reloads the signer when modification time of the file changes, until Close is called
+func (s *ReloadableSigner) watch(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(s.file)
+			if err != nil {
+				s.setErr(err)
+				continue
+			}
+			s.mu.RLock()
+			changed := !info.ModTime().Equal(s.modTime) || s.signer == nil
+			due := !time.Now().Before(s.retryAt)
+			s.mu.RUnlock()
+			if changed && due {
+				s.Reload()
+			}
+		}
+	}
+}
+
+// Reload finalizes the current signer once in-flight signing operations complete and loads a new one
+func (s *ReloadableSigner) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, err := os.Stat(s.file)
+	if err != nil {
+		s.fail(err)
+		return err
+	}
+	finalize(s.signer)
+	s.signer = nil
+	signer, err := s.load()
+	if err != nil {
+		s.fail(err)
+		return err
+	}
+	s.signer = signer
+	s.modTime = info.ModTime()
+	s.err = nil
+	s.failures = 0
+	s.retryAt = time.Time{}
+	return nil
+}
+
+// fail records error of a reload and delays the next retry by the watcher, doubling the delay after every
+// consecutive failure up to maxReloadBackoff. Must be called with s.mu locked
+func (s *ReloadableSigner) fail(err error) {
+	s.err = err
+	s.failures++
+	delay := s.interval
+	for i := 1; i < s.failures && delay < maxReloadBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxReloadBackoff {
+		delay = maxReloadBackoff
+	}
+	s.retryAt = time.Now().Add(delay)
+}
+
+// setErr records error of the last reload
+func (s *ReloadableSigner) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Err returns error of the last failed reload, or nil if the last reload succeeded
+func (s *ReloadableSigner) Err() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.err
+}
+
+// SignPKCS1v15 signs data with the currently active signer
+func (s *ReloadableSigner) SignPKCS1v15(data []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.signer == nil {
+		return nil, signerError(fmt.Errorf("%w: signer is closed", ErrSignerNotReady))
+	}
+	return s.signer.SignPKCS1v15(data)
+}
+
+// GetCertificate returns certificate of the currently active signer, e.g. for health or expiry reporting
+func (s *ReloadableSigner) GetCertificate() (x509.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.signer == nil {
+		return x509.Certificate{}, signerError(fmt.Errorf("%w: signer is closed", ErrSignerNotReady))
+	}
+	cert, err := certificateOf(s.signer)
+	if err != nil {
+		return x509.Certificate{}, err
+	}
+	return *cert, nil
+}
+
+// Close stops watching and finalizes the active signer
+func (s *ReloadableSigner) Close() {
+	select {
+	case <-s.stop:
+		return
+	default:
+		close(s.stop)
+	}
+	<-s.done
+
+	s.mu.Lock()
+	old := s.signer
+	s.signer = nil
+	s.mu.Unlock()
+	finalize(old)
+}
+
+// finalize finalizes signer if it has Finalize method, see finalizeSafeNet for SafeNet sessions
+func finalize(signer Signer) {
+	if s, ok := signer.(*safenet.SafeNet); ok {
+		finalizeSafeNet(s)
+		return
+	}
+	if f, ok := signer.(interface{ Finalize() error }); ok {
+		f.Finalize()
+	}
+}
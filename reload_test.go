@@ -0,0 +1,93 @@
+package iic
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// finalizableSigner is a signer which counts live instances of its loader
+type finalizableSigner struct {
+	Signer
+	loader *testLoader
+}
+
+func (s *finalizableSigner) Finalize() error {
+	s.loader.live--
+	return nil
+}
+
+// testLoader loads finalizableSigner, failing while fail is set. Reload holds the lock of ReloadableSigner,
+// so counters aren't guarded
+type testLoader struct {
+	signer Signer
+	fail   bool
+	live   int
+	most   int
+}
+
+func (l *testLoader) load() (Signer, error) {
+	if l.fail {
+		return nil, fmt.Errorf("token is removed")
+	}
+	l.live++
+	if l.live > l.most {
+		l.most = l.live
+	}
+	return &finalizableSigner{Signer: l.signer, loader: l}, nil
+}
+
+func TestReloadableSignerFinalizesBeforeLoading(t *testing.T) {
+	key, _ := newTestSigner(t)
+	loader := &testLoader{signer: key}
+	s, err := NewReloadableSigner(writeTestFile(t, "config.json", "{}"), loader.load, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := DigestForIIC(testInvoiceFields)
+
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if loader.most != 1 {
+		t.Errorf("%d signers were live at once, want the old one finalized before loading", loader.most)
+	}
+
+	loader.fail = true
+	if err := s.Reload(); err == nil {
+		t.Fatal("failed load is reported as success")
+	}
+	if loader.live != 0 {
+		t.Errorf("%d signers are live after a failed reload", loader.live)
+	}
+	if _, err := s.SignPKCS1v15(digest); !errors.Is(err, ErrSignerNotReady) {
+		t.Errorf("signing after a failed reload returned %v, want ErrSignerNotReady", err)
+	}
+	if s.Err() == nil {
+		t.Error("Err is nil after a failed reload")
+	}
+
+	loader.fail = false
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SignPKCS1v15(digest); err != nil {
+		t.Errorf("signing after a retried reload: %v", err)
+	}
+	s.Close()
+	if loader.live != 0 {
+		t.Errorf("%d signers are live after Close", loader.live)
+	}
+}
+
+func TestReloadableSignerBackoff(t *testing.T) {
+	s := &ReloadableSigner{interval: time.Minute}
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, maxReloadBackoff, maxReloadBackoff} {
+		started := time.Now()
+		s.fail(fmt.Errorf("token is removed"))
+		if delay := s.retryAt.Sub(started); delay < want || delay > want+time.Second {
+			t.Errorf("after %d failures retry is in %v, want %v", s.failures, delay, want)
+		}
+	}
+}
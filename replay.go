@@ -0,0 +1,232 @@
+package iic
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// SeenStore records keys of signed invoices, see ReplayKey, so the same ordinal isn't issued twice.
+// Seen reports whether the key is recorded, Mark records it once the signed invoice is saved.
+// It must be safe for concurrent use, as batches sign in several workers
+type SeenStore interface {
+	Seen(key string) (bool, error)
+	Mark(key string) error
+}
+
+// ReservingSeenStore is SeenStore which also reserves keys of invoices signed but not saved yet, so two workers
+// can't both sign the same ordinal. Reserve atomically reserves the key and reports whether it was already reserved
+// or recorded, Commit records the reserved key once the signed invoice is saved, Release drops a reservation
+// which wasn't committed, e.g. when saving failed. Seen reports reserved keys too. It's detected by type
+// assertion of Params.SeenStore, other stores are checked with Seen and marked after saving
+type ReservingSeenStore interface {
+	SeenStore
+	Reserve(key string) (bool, error)
+	Commit(key string) error
+	Release(key string) error
+}
+
+// ReplayKey returns the key of SeenStore identifying an invoice by TIN, BusinUnitCode, TCRCode and InvOrdNum.
+// Ordinals restart from 1 every year, so the year of IssueDateTime is a part of the key too.
+// Orders of parameters are the same as for GenerateIIC
+func ReplayKey(params [7]string) string {
+	year := params[1]
+	if len(year) > 4 {
+		year = year[:4]
+	}
+	return strings.Join([]string{params[0], params[3], params[4], year, params[2]}, "|")
+}
+
+// checkReplay fails with ErrReplayed if params.SeenStore has already seen the invoice of given values,
+// unless params.ForceReplay is set. It's checked before signing, so a replay costs no signature, see reserveReplay
+func checkReplay(fields [7]string, params *Params) error {
+	if params.SeenStore == nil || params.ForceReplay {
+		return nil
+	}
+	key := ReplayKey(fields)
+	seen, err := params.SeenStore.Seen(key)
+	if err != nil {
+		return fmt.Errorf("seen store: %v", err)
+	}
+	if seen {
+		return fmt.Errorf("%w: %s", ErrReplayed, key)
+	}
+	return nil
+}
+
+// reservation is a key of params.SeenStore reserved for an invoice whose IIC is generated, but not saved yet.
+// Owned is unset for keys seen before and signed anyway with params.ForceReplay, which aren't released
+type reservation struct {
+	key   string
+	owned bool
+}
+
+// reserveReplay reserves the invoice of given values in params.SeenStore once its IIC is generated, failing with
+// ErrReplayed if it's already reserved or recorded, unless params.ForceReplay is set. It's the atomic counterpart
+// of checkReplay: of two workers signing the same ordinal concurrently, only the first one gets the reservation,
+// if the store is ReservingSeenStore. Other stores are only checked again. The reservation is pending until settleReplay
+func reserveReplay(fields [7]string, params *Params) error {
+	if params.SeenStore == nil {
+		return nil
+	}
+	key := ReplayKey(fields)
+	var seen bool
+	var err error
+	if store, ok := params.SeenStore.(ReservingSeenStore); ok {
+		seen, err = store.Reserve(key)
+	} else {
+		seen, err = params.SeenStore.Seen(key)
+	}
+	if err != nil {
+		return fmt.Errorf("seen store: %v", err)
+	}
+	if seen && !params.ForceReplay {
+		return fmt.Errorf("%w: %s", ErrReplayed, key)
+	}
+	params.reserved = append(params.reserved, reservation{key: key, owned: !seen})
+	return nil
+}
+
+// settleReplay commits reservations of params once the signed document is saved, or releases them otherwise
+func (params *Params) settleReplay(commit bool) error {
+	reserved := params.takeReservations()
+	return settleReservations(params.SeenStore, reserved, commit)
+}
+
+// takeReservations returns pending reservations of params and leaves them to the caller to settle
+func (params *Params) takeReservations() []reservation {
+	reserved := params.reserved
+	params.reserved = nil
+	return reserved
+}
+
+// settleReservations commits or releases reserved keys of store, see settleReplay. Keys of stores which
+// aren't ReservingSeenStore are marked on commit, there's nothing to release
+func settleReservations(store SeenStore, reserved []reservation, commit bool) error {
+	reserving, _ := store.(ReservingSeenStore)
+	var first error
+	for _, r := range reserved {
+		var err error
+		switch {
+		case commit && reserving != nil:
+			err = reserving.Commit(r.key)
+		case commit:
+			err = store.Mark(r.key)
+		case r.owned && reserving != nil:
+			err = reserving.Release(r.key)
+		}
+		if err != nil && first == nil {
+			first = fmt.Errorf("seen store: %v", err)
+		}
+	}
+	return first
+}
+
+// MemorySeenStore is ReservingSeenStore kept in memory, e.g. for a single run of a pipeline
+type MemorySeenStore struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+// NewMemorySeenStore creates empty MemorySeenStore
+func NewMemorySeenStore() *MemorySeenStore {
+	return &MemorySeenStore{keys: map[string]bool{}}
+}
+
+// Seen reports whether key is reserved or recorded
+func (s *MemorySeenStore) Seen(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.keys[key]
+	return ok, nil
+}
+
+// Reserve reserves key unless it's already reserved or recorded, which is reported
+func (s *MemorySeenStore) Reserve(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[key]; ok {
+		return true, nil
+	}
+	s.keys[key] = false
+	return false, nil
+}
+
+// Mark records key
+func (s *MemorySeenStore) Mark(key string) error {
+	return s.Commit(key)
+}
+
+// Commit records key
+func (s *MemorySeenStore) Commit(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key] = true
+	return nil
+}
+
+// Release drops reservation of key, recorded keys are kept
+func (s *MemorySeenStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if committed, ok := s.keys[key]; ok && !committed {
+		delete(s.keys, key)
+	}
+	return nil
+}
+
+// FileSeenStore is ReservingSeenStore persisted in a file with a key per line, so seen ordinals survive restarts.
+// Keys are appended and flushed to disk as they're committed, reservations are kept in memory only
+type FileSeenStore struct {
+	MemorySeenStore
+	file *os.File
+}
+
+// OpenFileSeenStore opens FileSeenStore of path, creating the file if it doesn't exist
+func OpenFileSeenStore(path string) (*FileSeenStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	store := &FileSeenStore{MemorySeenStore: MemorySeenStore{keys: map[string]bool{}}, file: file}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if key := strings.TrimSpace(scanner.Text()); len(key) > 0 {
+			store.keys[key] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Mark appends key to the file and records it
+func (s *FileSeenStore) Mark(key string) error {
+	return s.Commit(key)
+}
+
+// Commit appends key to the file and records it
+func (s *FileSeenStore) Commit(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys[key] {
+		return nil
+	}
+	if _, err := s.file.WriteString(key + "\n"); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.keys[key] = true
+	return nil
+}
+
+// Close closes the file
+func (s *FileSeenStore) Close() error {
+	return s.file.Close()
+}
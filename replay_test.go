@@ -0,0 +1,180 @@
+package iic
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// bundleKeys returns ReplayKey of every Invoice of testBundle with given ordinals
+func bundleKeys(t *testing.T, ordinals ...string) []string {
+	t.Helper()
+	doc := readTestDocument(t, testBundle(ordinals...))
+	keys := []string{}
+	for _, invoice := range doc.FindElements("//Invoice") {
+		fields, err := parseInvoice(doc, invoice, ParseOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, ReplayKey(fields))
+	}
+	return keys
+}
+
+// seenKeys returns which of keys store has seen
+func seenKeys(t *testing.T, store SeenStore, keys []string) []bool {
+	t.Helper()
+	seen := make([]bool, len(keys))
+	for i, key := range keys {
+		var err error
+		if seen[i], err = store.Seen(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return seen
+}
+
+func TestStageReservations(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	keys := bundleKeys(t, "1", "2")
+
+	// the second invoice fails after the first one is reserved
+	store := NewMemorySeenStore()
+	broken := strings.Replace(testBundle("1", "2"), `InvOrdNum="2" BusinUnitCode="bb123bb123"`, `InvOrdNum="2"`, 1)
+	if _, err := Stage(signer, readTestDocument(t, broken), &Params{SeenStore: store}); err == nil {
+		t.Fatal("Stage of a broken invoice succeeded")
+	}
+	if seen := seenKeys(t, store, keys); seen[0] || seen[1] {
+		t.Errorf("aborted Stage left reservations %v", seen)
+	}
+
+	staged, err := Stage(signer, readTestDocument(t, testBundle("1", "2")), &Params{SeenStore: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Discard(staged); err != nil {
+		t.Fatal(err)
+	}
+	if seen := seenKeys(t, store, keys); seen[0] || seen[1] {
+		t.Errorf("Discard left reservations %v", seen)
+	}
+
+	staged, err = Stage(signer, readTestDocument(t, testBundle("1", "2")), &Params{SeenStore: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Stage(signer, readTestDocument(t, testBundle("2")), &Params{SeenStore: store}); !errors.Is(err, ErrReplayed) {
+		t.Errorf("Stage of a reserved ordinal returned %v, want ErrReplayed", err)
+	}
+	if _, err := Commit(staged); err != nil {
+		t.Fatal(err)
+	}
+	if err := Discard(staged); err != nil {
+		t.Fatal(err)
+	}
+	if seen := seenKeys(t, store, keys); !seen[0] || !seen[1] {
+		t.Errorf("Commit recorded %v", seen)
+	}
+}
+
+func TestWriteIICFailedWriteReleases(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	store := NewMemorySeenStore()
+	in := writeTestFile(t, "in.xml", testInvoice)
+	missing := filepath.Join(t.TempDir(), "missing", "out.xml")
+	if err := WriteIIC(&Params{Signer: signer, InFile: in, OutFile: missing, SeenStore: store}); err == nil {
+		t.Fatal("write into a missing directory succeeded")
+	}
+	key := ReplayKey(testInvoiceFields)
+	if seen, _ := store.Seen(key); seen {
+		t.Error("failed write left the ordinal reserved")
+	}
+
+	out := filepath.Join(t.TempDir(), "out.xml")
+	if err := WriteIIC(&Params{Signer: signer, InFile: in, OutFile: out, SeenStore: store}); err != nil {
+		t.Fatal(err)
+	}
+	if seen, _ := store.Seen(key); !seen {
+		t.Error("written ordinal isn't recorded")
+	}
+	if err := WriteIIC(&Params{Signer: signer, InFile: in, OutFile: out, SeenStore: store}); !errors.Is(err, ErrReplayed) {
+		t.Errorf("second WriteIIC returned %v, want ErrReplayed", err)
+	}
+}
+
+func TestMemorySeenStoreReserveAtomic(t *testing.T) {
+	store := NewMemorySeenStore()
+	won := make(chan bool, 32)
+	wg := sync.WaitGroup{}
+	for i := 0; i < cap(won); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seen, err := store.Reserve("key")
+			if err != nil {
+				t.Error(err)
+			}
+			won <- !seen
+		}()
+	}
+	wg.Wait()
+	close(won)
+	winners := 0
+	for w := range won {
+		if w {
+			winners++
+		}
+	}
+	if winners != 1 {
+		t.Errorf("%d concurrent reservations succeeded, want 1", winners)
+	}
+}
+
+// markingStore is SeenStore without reservations
+type markingStore struct {
+	mu     sync.Mutex
+	marked map[string]bool
+}
+
+func (s *markingStore) Seen(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.marked[key], nil
+}
+
+func (s *markingStore) Mark(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked[key] = true
+	return nil
+}
+
+func TestWriteIICMarkingStore(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	store := &markingStore{marked: map[string]bool{}}
+	in := writeTestFile(t, "in.xml", testInvoice)
+	missing := filepath.Join(t.TempDir(), "missing", "out.xml")
+	if err := WriteIIC(&Params{Signer: signer, InFile: in, OutFile: missing, SeenStore: store}); err == nil {
+		t.Fatal("write into a missing directory succeeded")
+	}
+	key := ReplayKey(testInvoiceFields)
+	if seen, _ := store.Seen(key); seen {
+		t.Error("failed write marked the ordinal")
+	}
+
+	out := filepath.Join(t.TempDir(), "out.xml")
+	if err := WriteIIC(&Params{Signer: signer, InFile: in, OutFile: out, SeenStore: store}); err != nil {
+		t.Fatal(err)
+	}
+	if seen, _ := store.Seen(key); !seen {
+		t.Error("written ordinal isn't marked")
+	}
+	if err := WriteIIC(&Params{Signer: signer, InFile: in, OutFile: out, SeenStore: store}); !errors.Is(err, ErrReplayed) {
+		t.Errorf("second WriteIIC returned %v, want ErrReplayed", err)
+	}
+	if err := WriteIIC(&Params{Signer: signer, InFile: in, OutFile: out, SeenStore: store, ForceReplay: true}); err != nil {
+		t.Errorf("forced WriteIIC returned %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package iic
+
+import (
+	"errors"
+	"sync"
+)
+
+// retryBudget counts retries left for a whole batch, shared by its workers. Negative left means no limit
+type retryBudget struct {
+	mu   sync.Mutex
+	left int
+}
+
+// newRetryBudget creates budget of given number of retries, zero means no limit
+func newRetryBudget(retries int) *retryBudget {
+	if retries <= 0 {
+		return &retryBudget{left: -1}
+	}
+	return &retryBudget{left: retries}
+}
+
+// take consumes a retry, returns false if the budget is exhausted
+func (b *retryBudget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.left == 0 {
+		return false
+	}
+	if b.left > 0 {
+		b.left--
+	}
+	return true
+}
+
+// retryable reports whether err is a signer failure which may pass on retry. Rejected PIN and locked
+// token aren't retried, as retries with the same PIN lock the token
+func retryable(err error) bool {
+	return errors.Is(err, ErrSigner) && !errors.Is(err, ErrBadPIN) && !errors.Is(err, ErrTokenLocked)
+}
@@ -0,0 +1,117 @@
+package iic
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+
+	"github.com/beevik/etree"
+)
+
+// Rule represents a business rule checked over an Invoice of doc and values of its IIC beyond their format,
+// e.g. cross-field consistency. Check returns nil if the invoice satisfies the rule
+type Rule struct {
+	Name  string
+	Check func(doc *etree.Document, invoice *etree.Element, fields [7]string) error
+}
+
+// BusinessRules composes rules checked by Validate
+type BusinessRules struct {
+	rules []Rule
+}
+
+// NewBusinessRules creates BusinessRules of given rules, e.g. StandardRules
+func NewBusinessRules(rules ...Rule) *BusinessRules {
+	return &BusinessRules{rules: append([]Rule{}, rules...)}
+}
+
+// Add appends custom rules
+func (r *BusinessRules) Add(rules ...Rule) {
+	r.rules = append(r.rules, rules...)
+}
+
+// Validate checks every Invoice of doc with every rule and returns all violations at once as *ValidationError.
+// Invoices whose values can't be parsed are reported without checking the rules
+func (r *BusinessRules) Validate(doc *etree.Document, opts ParseOptions) error {
+	invoices := doc.FindElements("//Invoice")
+	if len(invoices) == 0 {
+		return validationError([]error{fmt.Errorf("can't find element %s", "//Invoice")})
+	}
+
+	errs := []error{}
+	for i, invoice := range invoices {
+		fields, lookupErrs := lookupInvoice(doc, invoice, opts)
+		for _, err := range lookupErrs {
+			errs = append(errs, fmt.Errorf("invoice %d: %v", i+1, err))
+		}
+		if len(lookupErrs) > 0 {
+			continue
+		}
+		for _, rule := range r.rules {
+			if err := rule.Check(doc, invoice, fields); err != nil {
+				errs = append(errs, fmt.Errorf("invoice %d: %s: %v", i+1, rule.Name, err))
+			}
+		}
+	}
+	return validationError(errs)
+}
+
+// StandardRules returns business rules of fiscalization in Montenegro: RuleCashTCR, RuleCorrectiveReference,
+// RuleAdvanceReference and RuleVATTotal
+func StandardRules() []Rule {
+	return []Rule{RuleCashTCR, RuleCorrectiveReference, RuleAdvanceReference, RuleVATTotal}
+}
+
+// iicRegexp matches IIC, i.e. md5 hash in hex
+var iicRegexp = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+// RuleCashTCR requires TCRCode of cash invoices, which are always issued on a registered cash register
+var RuleCashTCR = Rule{
+	Name: "cash-tcr",
+	Check: func(doc *etree.Document, invoice *etree.Element, fields [7]string) error {
+		if invoice.SelectAttrValue("TypeOfInv", "") == "CASH" && len(fields[4]) == 0 {
+			return fmt.Errorf("CASH invoice must have TCRCode")
+		}
+		return nil
+	},
+}
+
+// RuleCorrectiveReference requires corrective invoices, i.e. those with CorrectiveInv, to reference IIC and
+// IssueDateTime of the corrected invoice
+var RuleCorrectiveReference = Rule{
+	Name: "corrective-reference",
+	Check: func(doc *etree.Document, invoice *etree.Element, fields [7]string) error {
+		corrective := invoice.SelectElement("CorrectiveInv")
+		if corrective == nil {
+			return nil
+		}
+		if ref := corrective.SelectAttrValue("IICRef", ""); !iicRegexp.MatchString(ref) {
+			return fmt.Errorf("CorrectiveInv/IICRef %q is not an IIC of the corrected invoice", ref)
+		}
+		if issued := corrective.SelectAttrValue("IssueDateTime", ""); len(issued) == 0 {
+			return fmt.Errorf("CorrectiveInv must have IssueDateTime of the corrected invoice")
+		}
+		return nil
+	},
+}
+
+// RuleVATTotal requires TotPrice to equal TotPriceWoVAT plus TotVATAmt when the invoice has both
+var RuleVATTotal = Rule{
+	Name: "vat-total",
+	Check: func(doc *etree.Document, invoice *etree.Element, fields [7]string) error {
+		withoutVAT, errWoVAT := strconv.ParseFloat(invoice.SelectAttrValue("TotPriceWoVAT", ""), 64)
+		vat, errVAT := strconv.ParseFloat(invoice.SelectAttrValue("TotVATAmt", ""), 64)
+		if errWoVAT != nil || errVAT != nil {
+			return nil
+		}
+		total, err := strconv.ParseFloat(fields[6], 64)
+		if err != nil {
+			return fmt.Errorf("TotPrice %s is not a number", fields[6])
+		}
+		if math.Abs(withoutVAT+vat-total) >= 0.005 {
+			return fmt.Errorf("TotPrice %s isn't TotPriceWoVAT plus TotVATAmt, %.2f", fields[6], withoutVAT+vat)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,95 @@
+package iic
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+
+	"github.com/beevik/etree"
+)
+
+// scanFirstInvoice parses XML document from buf like parseDocument, but builds only the first Invoice and elements
+// outside of Invoices, e.g. shared Sellers, as parse doesn't read other invoices. The first Invoice is the one
+// found by //Invoice: the shallowest one, and the earliest of equally deep ones. Other invoices are only tokenized,
+// which is about twice as fast for consolidated documents. Use only when the first Invoice is needed, as lookups
+// over the whole document, e.g. //PayMethod, may differ. Errors are those of parseDocument
+func scanFirstInvoice(buf []byte) (*etree.Document, error) {
+	doc := etree.NewDocument()
+	decoder := xml.NewDecoder(bytes.NewReader(bytes.TrimPrefix(buf, utf8BOM)))
+	stack := []*etree.Element{&doc.Element}
+	var first *etree.Element
+	firstDepth, skipped := 0, 0
+	// RawToken doesn't check that end tags match start tags, parseDocument reports mismatches
+	open := openElements{}
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			if open.end() != nil {
+				return fallbackDocument(buf)
+			}
+			return doc, nil
+		}
+		if err != nil || len(stack) == 0 || open.track(token) != nil {
+			return fallbackDocument(buf)
+		}
+		if skipped > 0 {
+			switch token.(type) {
+			case xml.StartElement:
+				skipped++
+			case xml.EndElement:
+				skipped--
+			}
+			continue
+		}
+
+		top := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			isFirst := false
+			if t.Name.Local == "Invoice" && !contains(stack, first) {
+				if first != nil && len(stack) >= firstDepth {
+					skipped = 1
+					continue
+				}
+				if first != nil {
+					first.Parent().RemoveChild(first)
+				}
+				isFirst = true
+			}
+			elem := top.CreateElement(qualifiedName(t.Name))
+			for _, attr := range t.Attr {
+				elem.CreateAttr(qualifiedName(attr.Name), attr.Value)
+			}
+			if isFirst {
+				first, firstDepth = elem, len(stack)
+			}
+			stack = append(stack, elem)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			top.CreateCharData(string(t))
+		case xml.Comment:
+			top.CreateComment(string(t))
+		case xml.Directive:
+			top.CreateDirective(string(t))
+		case xml.ProcInst:
+			top.CreateProcInst(t.Target, string(t.Inst))
+		}
+	}
+}
+
+// contains reports whether stack contains elem
+func contains(stack []*etree.Element, elem *etree.Element) bool {
+	for _, e := range stack {
+		if e == elem {
+			return true
+		}
+	}
+	return false
+}
+
+// fallbackDocument parses buf with parseDocument, so scanFirstInvoice fails with the same errors
+func fallbackDocument(buf []byte) (*etree.Document, error) {
+	doc, _, err := parseDocument(buf, nil)
+	return doc, err
+}
@@ -0,0 +1,117 @@
+package iic
+
+import (
+	"strings"
+	"testing"
+)
+
+// testSharedSellerBundle is a bundle whose invoices refer to a Seller shared by them
+const testSharedSellerBundle = `<Invoices>
+  <Seller IDType="TIN" IDNum="87654321"/>
+  <Invoice IssueDateTime="2019-06-12T17:05:43+02:00" InvOrdNum="7" BusinUnitCode="bb123bb123" TCRCode="cc123cc123" SoftCode="ss123ss123" TotPrice="1.00"/>
+  <Invoice IssueDateTime="2019-06-12T17:05:43+02:00" InvOrdNum="8" BusinUnitCode="bb123bb123" TCRCode="cc123cc123" SoftCode="ss123ss123" TotPrice="2.00"/>
+</Invoices>`
+
+// testNestedBundle has a deeply nested Invoice before a shallower one, which //Invoice finds first
+var testNestedBundle = "<Root><Batch>" + strings.TrimPrefix(testBundle("1"), `<?xml version="1.0" encoding="UTF-8"?>`) +
+	"</Batch>" + strings.Replace(testInvoice, `<?xml version="1.0" encoding="UTF-8"?>`, "", 1) + "</Root>"
+
+// testSkippedMismatch is a bundle whose second invoice, which the scan skips, closes its Invoice before its Seller
+var testSkippedMismatch = func() string {
+	bundle := testBundle("1", "2")
+	at := strings.LastIndex(bundle, "/>\n  </Invoice>")
+	return bundle[:at] + ">\n  </Invoice></Seller>" + bundle[at+len("/>\n  </Invoice>"):]
+}()
+
+func TestScanFirstInvoiceMatchesDOM(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		opts    ParseOptions
+	}{
+		{"single", testInvoice, ParseOptions{}},
+		{"BOM", string(utf8BOM) + testInvoice, ParseOptions{}},
+		{"bundle", testBundle("3", "1", "2"), ParseOptions{}},
+		{"elements", testElementInvoice, ParseOptions{FieldMode: FieldElements}},
+		{"shared seller", testSharedSellerBundle, ParseOptions{}},
+		{"nested", testNestedBundle, ParseOptions{}},
+		{"no invoice", `<Invoices><Seller IDNum="12345678"/></Invoices>`, ParseOptions{}},
+		{"missing value", strings.Replace(testInvoice, `InvOrdNum="9952"`, "", 1), ParseOptions{}},
+		{"malformed", strings.Replace(testInvoice, "</Invoice>", "", 1), ParseOptions{}},
+		{"mismatched end tags", `<Invoices><Invoice IssueDateTime="2019-06-12T17:05:43+02:00" InvOrdNum="1" BusinUnitCode="bb123bb123" TCRCode="cc123cc123" SoftCode="ss123ss123" TotPrice="1.00"><Seller IDNum="12345678"></Invoice></Seller></Invoices>`, ParseOptions{}},
+		{"mismatched end tags of a skipped invoice", testSkippedMismatch, ParseOptions{}},
+		{"unclosed", strings.TrimSuffix(testBundle("1", "2"), "</Invoices>\n"), ParseOptions{}},
+		{"undeclared prefix", `<Invoices><x:Invoice/></Invoices>`, ParseOptions{}},
+	}
+	for _, test := range tests {
+		buf := []byte(test.content)
+		want, wantErr := [7]string{}, error(nil)
+		doc, _, err := parseDocument(buf, nil)
+		if err == nil {
+			want, wantErr = parse(doc, test.opts)
+		} else {
+			wantErr = err
+		}
+
+		got, gotErr := [7]string{}, error(nil)
+		scanned, err := scanFirstInvoice(buf)
+		if err == nil {
+			got, gotErr = parse(scanned, test.opts)
+		} else {
+			gotErr = err
+		}
+
+		if got != want || (gotErr == nil) != (wantErr == nil) || gotErr != nil && gotErr.Error() != wantErr.Error() {
+			t.Errorf("%s: scan returned %v, %v, DOM returned %v, %v", test.name, got, gotErr, want, wantErr)
+		}
+	}
+}
+
+func TestScanFirstInvoiceMismatchedTags(t *testing.T) {
+	mismatched := []string{
+		`<Invoices><Invoice InvOrdNum="1"><Seller IDNum="12345678"></Invoice></Seller></Invoices>`,
+		testSkippedMismatch,
+		strings.TrimSuffix(testBundle("1", "2"), "</Invoices>\n"),
+	}
+	for _, content := range mismatched {
+		if _, err := scanFirstInvoice([]byte(content)); err == nil {
+			t.Errorf("scan accepts malformed document %s", content)
+		}
+	}
+}
+
+// testLargeBundle is a consolidated document of 5000 invoices
+var testLargeBundle = func() []byte {
+	ordinals := make([]string, 5000)
+	for i := range ordinals {
+		ordinals[i] = "1"
+	}
+	return []byte(testBundle(ordinals...))
+}()
+
+// BenchmarkScanFirstInvoice measures the scan of a large document, compare with BenchmarkParseDocumentFirstInvoice
+func BenchmarkScanFirstInvoice(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		doc, err := scanFirstInvoice(testLargeBundle)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := parse(doc, ParseOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseDocumentFirstInvoice(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		doc, _, err := parseDocument(testLargeBundle, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := parse(doc, ParseOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
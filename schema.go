@@ -0,0 +1,43 @@
+package iic
+
+import (
+	"fmt"
+
+	"github.com/beevik/etree"
+)
+
+// DetectSchemaVersion returns version of the fiscalization schema declared by Version attribute of the root
+// element, e.g. <RegisterInvoiceRequest Version="1">
+func DetectSchemaVersion(doc *etree.Document) (string, error) {
+	root := doc.Root()
+	if root == nil {
+		return "", fmt.Errorf("document has no root element")
+	}
+	return attributeOf(root, "Version")
+}
+
+// checkSchemaVersion fails with ErrSchemaVersion if required version is set and doc declares another one
+func checkSchemaVersion(doc *etree.Document, required string) error {
+	if len(required) == 0 {
+		return nil
+	}
+	version, err := DetectSchemaVersion(doc)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSchemaVersion, err)
+	}
+	if version != required {
+		return fmt.Errorf("%w: document has version %s, required %s", ErrSchemaVersion, version, required)
+	}
+	return nil
+}
+
+// checkDocument performs checks of the whole document before any of its invoices is signed
+func checkDocument(doc *etree.Document, params *Params) error {
+	if err := checkSchemaVersion(doc, params.SchemaVersion); err != nil {
+		return err
+	}
+	if err := checkPlacement(doc, params.IICPlacement); err != nil {
+		return err
+	}
+	return handleSignatures(doc, params.RemoveSignature)
+}
@@ -0,0 +1,93 @@
+package iic
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// selfTestParams is a fixed test invoice signed by SelfTest
+var selfTestParams = [7]string{
+	"12345678",
+	"2019-06-12T17:05:43+02:00",
+	"9952",
+	"bb123bb123",
+	"cc123cc123",
+	"ss123ss123",
+	"99.01",
+}
+
+// SelfTest signs a fixed test invoice, verifies the signature with public key of the signer's certificate
+// and checks that IIC is consistent with IICSignature, that signatures are of SignatureScheme, see CheckPKCS1v15,
+// and that the certificate allows signing, see CheckKeyUsage.
+// Signer must be a CertificateSource
+func SelfTest(signer Signer) error {
+	return SelfTestWithClock(signer, SystemClock{})
+}
+
+// SelfTestWithClock is the same as SelfTest, but checks certificate expiry against time of given clock
+func SelfTestWithClock(signer Signer, clock Clock) error {
+	cert, err := certificateOf(signer)
+	if err != nil {
+		return err
+	}
+	if now := clockOrSystem(clock).Now(); now.After(cert.NotAfter) {
+		return fmt.Errorf("certificate %s has expired at %s", cert.Subject, cert.NotAfter)
+	}
+	if err := CheckKeyUsage(cert); err != nil {
+		return err
+	}
+
+	if err := CheckPKCS1v15(signer); err != nil {
+		return err
+	}
+
+	digest := DigestForIIC(selfTestParams)
+	IIC, IICSignature, err := GenerateIICFromDigest(signer, digest)
+	if err != nil {
+		return err
+	}
+
+	signature, err := hex.DecodeString(IICSignature)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(cert.PublicKey, digest, signature); err != nil {
+		return fmt.Errorf("signature doesn't match certificate %s: %v", cert.Subject, err)
+	}
+	if fmt.Sprintf("%x", md5.Sum(signature)) != IIC {
+		return fmt.Errorf("IIC %s doesn't match IICSignature", IIC)
+	}
+	return nil
+}
+
+// TestVector is a sample invoice of the authority with its expected results, e.g. from the fiscalization
+// technical specification. Plain is the expected plain IIC string, it's checked only if set
+type TestVector struct {
+	Fields       [7]string
+	Plain        string
+	IIC          string
+	IICSignature string
+}
+
+// ValidateAgainstTestVector signs Fields of the vector with signer, which must hold the test key matching
+// the vector, and checks that the plain IIC string, IICSignature and IIC are exactly the expected ones.
+// RSASSA-PKCS1-v1_5 signatures are deterministic, so any difference means a regression in the order of values,
+// hashing or encoding
+func ValidateAgainstTestVector(signer Signer, vector TestVector) error {
+	if plain := PlainIIC(vector.Fields); len(vector.Plain) > 0 && plain != vector.Plain {
+		return fmt.Errorf("plain IIC %q doesn't match expected %q", plain, vector.Plain)
+	}
+	IIC, IICSignature, err := generateIIC(signer, vector.Fields)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(IICSignature, vector.IICSignature) {
+		return fmt.Errorf("IICSignature %s doesn't match expected %s", IICSignature, vector.IICSignature)
+	}
+	if !strings.EqualFold(IIC, vector.IIC) {
+		return fmt.Errorf("IIC %s doesn't match expected %s", IIC, vector.IIC)
+	}
+	return nil
+}
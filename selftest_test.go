@@ -0,0 +1,77 @@
+package iic
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// readRegressionVectors returns vectors of testdata/regression_vectors.json and a signer of
+// testdata/regression_key.pem they were made with. These aren't the authority's published vectors, which
+// aren't available to the repository: the key was generated for the tests and the vectors were computed with
+// it, so they only catch changes of the pipeline's output, not deviations from the specification
+func readRegressionVectors(t *testing.T) ([]TestVector, Signer) {
+	t.Helper()
+	buf, err := ioutil.ReadFile("testdata/regression_key.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(buf)
+	if block == nil {
+		t.Fatal("can't find key in testdata/regression_key.pem")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewKeySigner(key, AlgorithmRSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err = ioutil.ReadFile("testdata/regression_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors := []TestVector{}
+	if err := json.Unmarshal(buf, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	return vectors, signer
+}
+
+// TestValidateAgainstRegressionVectors checks that the pipeline still reproduces the regression vectors,
+// see readRegressionVectors. It's no substitute for the authority's test vectors
+func TestValidateAgainstRegressionVectors(t *testing.T) {
+	vectors, signer := readRegressionVectors(t)
+	if len(vectors) == 0 {
+		t.Fatal("testdata/regression_vectors.json has no vectors")
+	}
+	for i, vector := range vectors {
+		if err := ValidateAgainstTestVector(signer, vector); err != nil {
+			t.Errorf("vector %d: %v", i, err)
+		}
+	}
+}
+
+func TestValidateAgainstRegressionVectorMismatch(t *testing.T) {
+	vectors, signer := readRegressionVectors(t)
+	vector := vectors[0]
+
+	swapped := vector
+	swapped.Fields[3], swapped.Fields[4] = swapped.Fields[4], swapped.Fields[3]
+	swapped.Plain = ""
+	other := vector
+	other.IIC = strings.Repeat("0", len(vector.IIC))
+	plain := vector
+	plain.Plain = strings.Replace(vector.Plain, "|", ";", -1)
+
+	for name, vector := range map[string]TestVector{"swapped values": swapped, "other IIC": other, "other plain": plain} {
+		if err := ValidateAgainstTestVector(signer, vector); err == nil {
+			t.Errorf("%s: vector is accepted", name)
+		}
+	}
+}
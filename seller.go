@@ -0,0 +1,67 @@
+package iic
+
+import (
+	"fmt"
+
+	"github.com/beevik/etree"
+)
+
+// sellerOf returns Seller of given Invoice. Seller inside the Invoice is used first. Otherwise, for consolidated
+// documents, the nearest enclosing element having Sellers outside of Invoices is looked up: its single Seller
+// is used, or the one whose Id equals SellerRef attribute of the Invoice when there are several.
+// It's an error if the Invoice can't be matched to exactly one Seller. Only the seller identifier of the Seller
+// feeds the IIC, see SellerID, its other content, e.g. name or address, is never read, so it may be missing or malformed
+func sellerOf(doc *etree.Document, invoice *etree.Element) (*etree.Element, error) {
+	if seller := invoice.FindElement(".//Seller"); seller != nil {
+		return seller, nil
+	}
+
+	ref := invoice.SelectAttrValue("SellerRef", "")
+	for parent := invoice.Parent(); parent != nil; parent = parent.Parent() {
+		sellers := sharedSellers(parent)
+		if len(sellers) == 0 {
+			continue
+		}
+		if len(ref) == 0 {
+			if len(sellers) == 1 {
+				return sellers[0], nil
+			}
+			return nil, fmt.Errorf("invoice matches %d elements Seller, set SellerRef to Id of one of them", len(sellers))
+		}
+		return sellerByRef(sellers, ref)
+	}
+	return nil, fmt.Errorf("can't find element %s", "//Seller")
+}
+
+// sellerByRef returns the only Seller of sellers with Id ref
+func sellerByRef(sellers []*etree.Element, ref string) (*etree.Element, error) {
+	var found *etree.Element
+	for _, seller := range sellers {
+		if seller.SelectAttrValue("Id", "") != ref {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("SellerRef %s matches several elements Seller", ref)
+		}
+		found = seller
+	}
+	if found == nil {
+		return nil, fmt.Errorf("SellerRef %s doesn't match any element Seller", ref)
+	}
+	return found, nil
+}
+
+// sharedSellers returns Sellers within elem which aren't inside of an Invoice
+func sharedSellers(elem *etree.Element) []*etree.Element {
+	sellers := []*etree.Element{}
+	for _, child := range elem.ChildElements() {
+		switch child.Tag {
+		case "Seller":
+			sellers = append(sellers, child)
+		case "Invoice":
+		default:
+			sellers = append(sellers, sharedSellers(child)...)
+		}
+	}
+	return sellers
+}
@@ -0,0 +1,38 @@
+package iic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSellerOptionalContent(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	seller := `<Seller IDType="TIN" IDNum="12345678" Name="A &amp; B"/>`
+	tests := []struct {
+		name   string
+		seller string
+	}{
+		{"full", `<Seller IDType="TIN" IDNum="12345678" Name="A &amp; B" Address="Njegoševa 1" Town="Podgorica" Country="MNE"><Address><Street>Njegoševa 1</Street><Zip>81000</Zip></Address></Seller>`},
+		{"without name and address", `<Seller IDType="TIN" IDNum="12345678"/>`},
+		{"malformed address", `<Seller IDType="TIN" IDNum="12345678" Name="" Town="81000" Country="Montenegro"><Address Zip="none"><Address/><Zip>x</Zip>???</Address><Name/></Seller>`},
+	}
+
+	IICs := map[string]bool{}
+	for _, test := range tests {
+		content := strings.Replace(testInvoice, seller, test.seller, 1)
+		if content == testInvoice {
+			t.Fatal("testInvoice has no Seller to replace")
+		}
+		staged, err := Stage(signer, readTestDocument(t, content), nil)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if fields := staged.Results[0].Fields; fields != testInvoiceFields {
+			t.Errorf("%s: fields are %v, want %v", test.name, fields, testInvoiceFields)
+		}
+		IICs[staged.Results[0].IIC] = true
+	}
+	if len(IICs) != 1 {
+		t.Errorf("Sellers differing in optional content yield %d different IICs", len(IICs))
+	}
+}
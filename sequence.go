@@ -0,0 +1,130 @@
+package iic
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// SequenceIssueKind describes a problem of InvOrdNum sequence found by VerifySequence
+type SequenceIssueKind int
+
+const (
+	// SequenceGap means that ordinals between two consecutive invoices are missing
+	SequenceGap SequenceIssueKind = iota
+	// SequenceDuplicate means that the ordinal was already used
+	SequenceDuplicate
+	// SequenceReset means that the ordinal is lower than the previous one within the same year
+	SequenceReset
+	// SequenceInvalid means that the invoice has non-numeric InvOrdNum or invalid IssueDateTime
+	SequenceInvalid
+)
+
+// String returns human readable name of the kind
+func (k SequenceIssueKind) String() string {
+	switch k {
+	case SequenceGap:
+		return "gap"
+	case SequenceDuplicate:
+		return "duplicate"
+	case SequenceReset:
+		return "reset"
+	case SequenceInvalid:
+		return "invalid"
+	default:
+		return "unknown"
+	}
+}
+
+// SequenceIssue represents a problem of InvOrdNum sequence of a business unit and cash register.
+// Index is position of the invoice in the input of VerifySequence, Previous is ordinal of the invoice
+// issued before it
+type SequenceIssue struct {
+	Kind          SequenceIssueKind
+	Index         int
+	BusinUnitCode string
+	TCRCode       string
+	Previous      int
+	Ordinal       int
+}
+
+func (i SequenceIssue) Error() string {
+	switch i.Kind {
+	case SequenceGap:
+		return fmt.Sprintf("%s/%s: gap between InvOrdNum %d and %d", i.BusinUnitCode, i.TCRCode, i.Previous, i.Ordinal)
+	case SequenceDuplicate:
+		return fmt.Sprintf("%s/%s: duplicate InvOrdNum %d", i.BusinUnitCode, i.TCRCode, i.Ordinal)
+	case SequenceReset:
+		return fmt.Sprintf("%s/%s: InvOrdNum %d follows %d", i.BusinUnitCode, i.TCRCode, i.Ordinal, i.Previous)
+	default:
+		return fmt.Sprintf("%s/%s: invoice %d has invalid InvOrdNum or IssueDateTime", i.BusinUnitCode, i.TCRCode, i.Index+1)
+	}
+}
+
+// sequenced is an invoice of VerifySequence with parsed ordinal and issue time
+type sequenced struct {
+	index   int
+	ordinal int
+	issued  time.Time
+}
+
+// VerifySequence checks that InvOrdNum increases by one with IssueDateTime within every business unit and
+// cash register, and reports gaps, duplicates and resets. IssueDateTime may be in any variant accepted by
+// CanonicalizeDateTime. Ordinals restart from 1 every year, so such restart isn't a reset. Invalid invoices
+// are reported first, other issues are ordered by business unit, cash register and issue time
+func VerifySequence(invoices []InvoiceFields) []SequenceIssue {
+	issues := []SequenceIssue{}
+	groups := map[[2]string][]sequenced{}
+	keys := [][2]string{}
+	for i, fields := range invoices {
+		key := [2]string{fields.BusinUnitCode, fields.TCRCode}
+		ordinal, errOrdinal := strconv.Atoi(fields.InvOrdNum)
+		issued, errIssued := parseDateTime(fields.IssueDateTime)
+		if errOrdinal != nil || errIssued != nil {
+			issues = append(issues, SequenceIssue{Kind: SequenceInvalid, Index: i, BusinUnitCode: key[0], TCRCode: key[1]})
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], sequenced{index: i, ordinal: ordinal, issued: issued})
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if keys[a][0] != keys[b][0] {
+			return keys[a][0] < keys[b][0]
+		}
+		return keys[a][1] < keys[b][1]
+	})
+
+	for _, key := range keys {
+		group := groups[key]
+		sort.SliceStable(group, func(a, b int) bool {
+			return group[a].issued.Before(group[b].issued)
+		})
+		seen := map[int]bool{}
+		for i, invoice := range group {
+			issue := SequenceIssue{Index: invoice.index, BusinUnitCode: key[0], TCRCode: key[1], Ordinal: invoice.ordinal}
+			if i > 0 {
+				issue.Previous = group[i-1].ordinal
+			}
+			if i > 0 && invoice.issued.Year() != group[i-1].issued.Year() && invoice.ordinal == 1 {
+				seen = map[int]bool{}
+			}
+			switch {
+			case seen[invoice.ordinal]:
+				issue.Kind = SequenceDuplicate
+				issues = append(issues, issue)
+			case i == 0 || len(seen) == 0:
+			case invoice.ordinal < issue.Previous:
+				issue.Kind = SequenceReset
+				issues = append(issues, issue)
+			case invoice.ordinal > issue.Previous+1:
+				issue.Kind = SequenceGap
+				issues = append(issues, issue)
+			}
+			seen[invoice.ordinal] = true
+		}
+	}
+	return issues
+}
@@ -0,0 +1,25 @@
+package iic
+
+import "testing"
+
+func TestVerifySequenceDateTimeVariants(t *testing.T) {
+	invoice := func(ordinal string, issued string) InvoiceFields {
+		return InvoiceFields{TIN: "12345678", IssueDateTime: issued, InvOrdNum: ordinal,
+			BusinUnitCode: "bb123bb123", TCRCode: "cc123cc123", SoftCode: "ss123ss123", TotPrice: "10.00"}
+	}
+	invoices := []InvoiceFields{
+		invoice("1", "2019-06-12T17:05:43+02:00"),
+		invoice("2", "2019-06-12T15:06Z"),
+		invoice("3", "2019-06-12T17:07:00.250+02:00"),
+		invoice("4", "2019-06-12T17:08:00Z"),
+	}
+	if issues := VerifySequence(invoices); len(issues) != 0 {
+		t.Errorf("VerifySequence reported %v", issues)
+	}
+
+	invoices = append(invoices, invoice("5", "2019-06-12 17:09"))
+	issues := VerifySequence(invoices)
+	if len(issues) != 1 || issues[0].Kind != SequenceInvalid || issues[0].Index != 4 {
+		t.Errorf("VerifySequence reported %v, want invoice 4 invalid", issues)
+	}
+}
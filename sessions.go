@@ -0,0 +1,388 @@
+package iic
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/miekg/pkcs11"
+	"github.com/noshto/dsig/pkg/safenet"
+)
+
+// openSessions holds SafeNet sessions opened by this package which aren't finalized yet, *safenet.SafeNet
+// initialized by initializeSafeNet and *sessionSigner of concurrent batches
+var openSessions = struct {
+	sync.Mutex
+	signers map[interface{}]struct{}
+}{signers: map[interface{}]struct{}{}}
+
+// OpenSessions returns number of SafeNet sessions opened by this package which aren't finalized yet.
+// A count climbing in a long-running service means that sessions leak and the token will run out of them
+func OpenSessions() int {
+	openSessions.Lock()
+	defer openSessions.Unlock()
+	return len(openSessions.signers)
+}
+
+// trackSession counts signer as open until it's finalized with finalizeSafeNet or untrackSession
+func trackSession(signer interface{}) {
+	openSessions.Lock()
+	defer openSessions.Unlock()
+	openSessions.signers[signer] = struct{}{}
+}
+
+// untrackSession stops counting signer as open
+func untrackSession(signer interface{}) {
+	openSessions.Lock()
+	defer openSessions.Unlock()
+	delete(openSessions.signers, signer)
+}
+
+// finalizeSafeNet finalizes signer and stops counting it as open. Sessions opened elsewhere are only finalized
+func finalizeSafeNet(signer *safenet.SafeNet) error {
+	untrackSession(signer)
+	return signer.Finalize()
+}
+
+// defaultLibPath returns path of the SafeNet PKCS#11 library used when config doesn't set one
+func defaultLibPath() string {
+	switch runtime.GOOS {
+	case "windows":
+		return "C:\\Windows\\System32\\eTPKCS11.dll"
+	case "darwin":
+		return "/usr/local/lib/libeTPkcs11.dylib"
+	default:
+		return "/usr/local/lib/libeTPkcs11.so"
+	}
+}
+
+// initializeModule initializes PKCS#11 library of ctx. Initialization is global to the process, so when
+// the library is already initialized, e.g. by a live *safenet.SafeNet, it's used as it is. Returns whether
+// ctx initialized the library and has to finalize it
+func initializeModule(ctx *pkcs11.Ctx) (bool, error) {
+	err := ctx.Initialize()
+	var code pkcs11.Error
+	if errors.As(err, &code) && code == pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// loadModule loads PKCS#11 library of config and initializes it, see initializeModule
+func loadModule(config *safenet.Config) (*pkcs11.Ctx, bool, error) {
+	path := config.LibPath
+	if len(path) == 0 {
+		path = defaultLibPath()
+	}
+	ctx := pkcs11.New(path)
+	if ctx == nil {
+		return nil, false, fmt.Errorf("can't load PKCS#11 library %s", path)
+	}
+	owned, err := initializeModule(ctx)
+	if err != nil {
+		ctx.Destroy()
+		return nil, false, err
+	}
+	return ctx, owned, nil
+}
+
+// TokenMaxSessions returns the number of sessions the token of config supports at a time, as reported by
+// the token. Zero means the token has no limit or doesn't report it. Sessions open in the process, e.g. of
+// a live *safenet.SafeNet, are left intact
+func TokenMaxSessions(config *safenet.Config) (int, error) {
+	ctx, owned, err := loadModule(config)
+	if err != nil {
+		return 0, signerError(err)
+	}
+	defer ctx.Destroy()
+	if owned {
+		defer ctx.Finalize()
+	}
+	slot, err := signingSlot(ctx)
+	if err != nil {
+		return 0, signerError(err)
+	}
+	return tokenMaxSessions(ctx, slot)
+}
+
+// tokenMaxSessions returns the session limit reported by the token in slot, see TokenMaxSessions
+func tokenMaxSessions(ctx *pkcs11.Ctx, slot uint) (int, error) {
+	info, err := ctx.GetTokenInfo(slot)
+	if err != nil {
+		return 0, signerError(err)
+	}
+	if info.MaxSessionCount == pkcs11.CK_EFFECTIVELY_INFINITE || info.MaxSessionCount == pkcs11.CK_UNAVAILABLE_INFORMATION {
+		return 0, nil
+	}
+	return int(info.MaxSessionCount), nil
+}
+
+// signingSlot returns the first slot with a token able to create RSASSA-PKCS1-v1_5 signatures,
+// the one *safenet.SafeNet signs with
+func signingSlot(ctx *pkcs11.Ctx) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, err
+	}
+	if len(slots) == 0 {
+		return 0, fmt.Errorf("%w: no slot with token", ErrTokenAbsent)
+	}
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)}
+	for _, slot := range slots {
+		if _, err := ctx.GetMechanismInfo(slot, mechanism); err == nil {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("no token is able to sign with RSA PKCS#1 v1.5")
+}
+
+// sessionPool is a single PKCS#11 context of SafeNet with a session per worker of a concurrent batch.
+// Initialization and login of PKCS#11 are global to the process, so a second *safenet.SafeNet can't sign
+// concurrently: its initialization fails and finalizes the library under the first one
+type sessionPool struct {
+	ctx     *pkcs11.Ctx
+	owned   bool
+	slot    uint
+	pin     string
+	signers []*sessionSigner
+}
+
+// openSessionPool opens a session for each of workers capped to maxSessions, or to the limit reported by
+// the token if it's zero. The reduction is recorded as WarningSessions. Fails if any session can't be opened
+func openSessionPool(config *safenet.Config, workers int, maxSessions int, pin PINFunc, params *Params) (*sessionPool, error) {
+	if pin != nil {
+		withPIN, err := configWithPIN(config, pin)
+		if err != nil {
+			return nil, err
+		}
+		defer ClearPIN(withPIN)
+		config = withPIN
+	}
+	ctx, owned, err := loadModule(config)
+	if err != nil {
+		return nil, initError(err)
+	}
+	pool := &sessionPool{ctx: ctx, owned: owned, pin: config.UnlockPin}
+	if pool.slot, err = signingSlot(ctx); err != nil {
+		pool.close()
+		return nil, signerError(err)
+	}
+
+	limit := maxSessions
+	if limit == 0 {
+		if limit, err = tokenMaxSessions(ctx, pool.slot); err != nil {
+			params.warnf(WarningSessions, "can't query session limit of the token: %v", err)
+		}
+	}
+	if limit > 0 && workers > limit {
+		params.warnf(WarningSessions, "reducing workers from %d to %d, the session limit of the token", workers, limit)
+		workers = limit
+	}
+
+	for w := 0; w < workers; w++ {
+		session, err := ctx.OpenSession(pool.slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		if err != nil {
+			pool.close()
+			return nil, signerError(fmt.Errorf("can't open session %d of %d: %w", w+1, workers, err))
+		}
+		signer := &sessionSigner{pool: pool, session: session}
+		pool.signers = append(pool.signers, signer)
+		trackSession(signer)
+	}
+	if err := ctx.Login(pool.signers[0].session, pkcs11.CKU_USER, pool.pin); err != nil {
+		var code pkcs11.Error
+		if !errors.As(err, &code) || code != pkcs11.CKR_USER_ALREADY_LOGGED_IN {
+			pool.close()
+			return nil, initError(err)
+		}
+	}
+	return pool, nil
+}
+
+// openSessionPoolWithin is the same as openSessionPool, but fails with ErrSignerNotReady if opening doesn't
+// complete within timeout, closing the late pool in background. Zero timeout waits forever
+func openSessionPoolWithin(timeout time.Duration, config *safenet.Config, workers int, maxSessions int, pin PINFunc, params *Params) (*sessionPool, error) {
+	if timeout <= 0 {
+		return openSessionPool(config, workers, maxSessions, pin, params)
+	}
+
+	// warnings are recorded into own Params of the goroutine and returned with the pool, as params of the caller
+	// mustn't be touched once it has given up
+	value, err := completeWithin(timeout, func() (interface{}, error) {
+		poolParams := &Params{Logger: params.Logger}
+		pool, err := openSessionPool(config, workers, maxSessions, pin, poolParams)
+		if err != nil {
+			return nil, err
+		}
+		return openedPool{pool: pool, warnings: poolParams.takeWarnings()}, nil
+	}, func(value interface{}) {
+		value.(openedPool).pool.close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	opened := value.(openedPool)
+	params.warnings = append(params.warnings, opened.warnings...)
+	return opened.pool, nil
+}
+
+// openedPool is sessionPool opened in background by openSessionPoolWithin along with warnings recorded
+// while opening it
+type openedPool struct {
+	pool     *sessionPool
+	warnings []Warning
+}
+
+// close closes every session of the pool and finalizes the library if the pool initialized it.
+// Login is global to the process, so it's kept if the library is used by others
+func (pool *sessionPool) close() {
+	for _, signer := range pool.signers {
+		untrackSession(signer)
+		if pool.owned {
+			pool.ctx.Logout(signer.session)
+		}
+		pool.ctx.CloseSession(signer.session)
+	}
+	pool.signers = nil
+	if pool.owned {
+		pool.ctx.Finalize()
+	}
+	pool.ctx.Destroy()
+	pool.pin = ""
+}
+
+// sessionSigner signs in a single session of sessionPool, like *safenet.SafeNet does in its own one.
+// It must not be used concurrently, each worker has its own
+type sessionSigner struct {
+	pool    *sessionPool
+	session pkcs11.SessionHandle
+}
+
+// digestInfoPrefix is DER prefix of DigestInfo of a SHA-256 digest, which CKM_RSA_PKCS expects before it
+var digestInfoPrefix = []byte{0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20}
+
+// SignPKCS1v15 creates RSASSA-PKCS1-v1_5 signature of sha256 hash data with the private key of the token
+func (s *sessionSigner) SignPKCS1v15(data []byte) ([]byte, error) {
+	ctx := s.pool.ctx
+	key, err := s.findObject(pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY))
+	if err != nil {
+		return nil, err
+	}
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)}
+	if err := ctx.SignInit(s.session, mechanism, key); err != nil {
+		return nil, err
+	}
+	if err := ctx.Login(s.session, pkcs11.CKU_CONTEXT_SPECIFIC, s.pool.pin); err != nil {
+		return nil, err
+	}
+	return ctx.Sign(s.session, append(append([]byte{}, digestInfoPrefix...), data...))
+}
+
+// GetCertificate returns X.509 certificate of the token
+func (s *sessionSigner) GetCertificate() (x509.Certificate, error) {
+	object, err := s.findObject(
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_CERTIFICATE),
+		pkcs11.NewAttribute(pkcs11.CKA_CERTIFICATE_TYPE, pkcs11.CKC_X_509),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+	)
+	if err != nil {
+		return x509.Certificate{}, err
+	}
+	attributes, err := s.pool.ctx.GetAttributeValue(s.session, object, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil)})
+	if err != nil {
+		return x509.Certificate{}, err
+	}
+	for _, attribute := range attributes {
+		if attribute.Type == pkcs11.CKA_VALUE {
+			cert, err := x509.ParseCertificate(attribute.Value)
+			if err != nil {
+				return x509.Certificate{}, err
+			}
+			return *cert, nil
+		}
+	}
+	return x509.Certificate{}, fmt.Errorf("token has no X.509 certificate")
+}
+
+// findObject returns the first object of the token matching template
+func (s *sessionSigner) findObject(template ...*pkcs11.Attribute) (pkcs11.ObjectHandle, error) {
+	ctx := s.pool.ctx
+	if err := ctx.FindObjectsInit(s.session, template); err != nil {
+		return 0, err
+	}
+	defer ctx.FindObjectsFinal(s.session)
+	objects, _, err := ctx.FindObjects(s.session, 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(objects) == 0 {
+		return 0, fmt.Errorf("token has no such object")
+	}
+	return objects[0], nil
+}
+
+// writeIICBatchConcurrent processes items with params.Workers workers, each with its own session of a single
+// SafeNet context, see sessionPool. Warnings about the batch are reported in results of every item.
+// Results of processed items are returned in order of params.Items
+func writeIICBatchConcurrent(params *BatchParams) ([]BatchResult, error) {
+	pool, err := openSessionPoolWithin(params.InitTimeout, params.SafenetConfig, params.Workers, params.MaxSessions, params.PINFunc, &params.Params)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.close()
+	prepared := params.takeWarnings()
+
+	results := make([]BatchResult, len(params.Items))
+	processed := make([]bool, len(params.Items))
+	items := make(chan int)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var aborted error
+	budget := newRetryBudget(params.RetryBudget)
+
+	wg := sync.WaitGroup{}
+	for _, signer := range pool.signers {
+		wg.Add(1)
+		go func(signer Signer) {
+			defer wg.Done()
+			for i := range items {
+				item := params.Items[i]
+				var err error
+				results[i], err = processItem(signer, params, item, budget)
+				results[i].Warnings = append(append([]Warning{}, prepared...), results[i].Warnings...)
+				processed[i] = true
+				if err == nil && params.Policy == AbortOnSignerError && errors.Is(results[i].Err, ErrSigner) {
+					err = results[i].Err
+				}
+				if err != nil {
+					stopOnce.Do(func() {
+						aborted = fmt.Errorf("batch aborted on %s: %w", item.InFile, err)
+						close(stop)
+					})
+				}
+			}
+		}(signer)
+	}
+
+dispatch:
+	for i := range params.Items {
+		select {
+		case items <- i:
+		case <-stop:
+			break dispatch
+		}
+	}
+	close(items)
+	wg.Wait()
+
+	ordered := make([]BatchResult, 0, len(results))
+	for i, result := range results {
+		if processed[i] {
+			ordered = append(ordered, result)
+		}
+	}
+	return ordered, aborted
+}
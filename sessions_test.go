@@ -0,0 +1,89 @@
+package iic
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpenSessionsBalanced(t *testing.T) {
+	before := OpenSessions()
+	signers := make([]*sessionSigner, 16)
+	wg := sync.WaitGroup{}
+	for i := range signers {
+		signers[i] = &sessionSigner{}
+		wg.Add(1)
+		go func(signer *sessionSigner) {
+			defer wg.Done()
+			trackSession(signer)
+		}(signers[i])
+	}
+	wg.Wait()
+	if open := OpenSessions(); open != before+len(signers) {
+		t.Errorf("OpenSessions = %d after opening %d sessions, want %d", open, len(signers), before+len(signers))
+	}
+
+	for _, signer := range signers {
+		wg.Add(1)
+		go func(signer *sessionSigner) {
+			defer wg.Done()
+			untrackSession(signer)
+			untrackSession(signer)
+		}(signer)
+	}
+	wg.Wait()
+	if open := OpenSessions(); open != before {
+		t.Errorf("OpenSessions = %d after finalizing every session, want %d", open, before)
+	}
+}
+
+// openTestPool returns open function of completeWithin tracking a pool of n sessions with a warning,
+// after released is closed, and abandon function untracking them
+func openTestPool(n int, released chan struct{}) (func() (interface{}, error), func(interface{})) {
+	open := func() (interface{}, error) {
+		<-released
+		pool := &sessionPool{}
+		for i := 0; i < n; i++ {
+			signer := &sessionSigner{pool: pool}
+			trackSession(signer)
+			pool.signers = append(pool.signers, signer)
+		}
+		poolParams := &Params{}
+		poolParams.warnf(WarningSessions, "reducing workers")
+		return openedPool{pool: pool, warnings: poolParams.takeWarnings()}, nil
+	}
+	abandon := func(value interface{}) {
+		for _, signer := range value.(openedPool).pool.signers {
+			untrackSession(signer)
+		}
+	}
+	return open, abandon
+}
+
+func TestSessionPoolLateCompletion(t *testing.T) {
+	before := OpenSessions()
+	released := make(chan struct{})
+	open, abandon := openTestPool(4, released)
+	if _, err := completeWithin(10*time.Millisecond, open, abandon); !errors.Is(err, ErrSignerNotReady) {
+		t.Fatalf("completeWithin returned %v, want ErrSignerNotReady", err)
+	}
+	close(released)
+	waitOpenSessions(t, before)
+
+	released = make(chan struct{})
+	close(released)
+	open, abandon = openTestPool(4, released)
+	value, err := completeWithin(time.Second, open, abandon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened := value.(openedPool)
+	if len(opened.warnings) != 1 || opened.warnings[0].Code != WarningSessions {
+		t.Errorf("warnings of the pool are %v, want the session warning", opened.warnings)
+	}
+	if open := OpenSessions(); open != before+4 {
+		t.Errorf("OpenSessions = %d, want %d", open, before+4)
+	}
+	abandon(opened)
+}
@@ -0,0 +1,101 @@
+package iic
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Severity overrides how findings of a validator or warnings of a code are treated, see Params.Severity
+type Severity int
+
+const (
+	// SeverityDefault keeps the finding as it is
+	SeverityDefault Severity = iota
+	// SeverityError makes the finding fail signing, e.g. to upgrade WarningSwapped
+	SeverityError
+	// SeverityWarning records the finding as a Warning of WarningValidation and signs anyway
+	SeverityWarning
+)
+
+// Stable ids of validators run when Params.Validate is set, keys of Params.Severity along with names
+// of warning codes, see WarningCode.String
+const (
+	// ValidatorEncoding checks encoding of every value, see ValidateEncoding
+	ValidatorEncoding = "encoding"
+	// ValidatorTIN checks format of the seller identifier, see ValidateSellerID
+	ValidatorTIN = "tin"
+	// ValidatorIssueDateTime checks that IssueDateTime is in RFC 3339 format
+	ValidatorIssueDateTime = "issuedatetime"
+	// ValidatorInvOrdNum checks that InvOrdNum is a positive integer
+	ValidatorInvOrdNum = "invordnum"
+	// ValidatorBusinUnitCode checks format of BusinUnitCode, see ValidateBusinUnitCode
+	ValidatorBusinUnitCode = "businunitcode"
+	// ValidatorTCRCode checks format of TCRCode, see ValidateTCRCode
+	ValidatorTCRCode = "tcrcode"
+	// ValidatorSoftCode checks format of SoftCode, see ValidateSoftCode
+	ValidatorSoftCode = "softcode"
+	// ValidatorTotPrice checks that TotPrice is a number
+	ValidatorTotPrice = "totprice"
+)
+
+// fieldValidatorIDs are ids of validators of the IIC values in the order of GenerateIIC parameters
+var fieldValidatorIDs = [7]string{
+	ValidatorTIN,
+	ValidatorIssueDateTime,
+	ValidatorInvOrdNum,
+	ValidatorBusinUnitCode,
+	ValidatorTCRCode,
+	ValidatorSoftCode,
+	ValidatorTotPrice,
+}
+
+// validatorError attaches id of the validator to its finding
+type validatorError struct {
+	id  string
+	err error
+}
+
+func (e *validatorError) Error() string {
+	return e.err.Error()
+}
+
+func (e *validatorError) Unwrap() error {
+	return e.err
+}
+
+// ValidatorOf returns id of the validator which found err, an element of ValidationError.Errors,
+// or empty string if it isn't known
+func ValidatorOf(err error) string {
+	var found *validatorError
+	if errors.As(err, &found) {
+		return found.id
+	}
+	return ""
+}
+
+// downgrade records findings of err, *ValidationError or nil, downgraded by params.Severity as warnings
+// and returns the others
+func (params *Params) downgrade(err error) error {
+	if err == nil || len(params.Severity) == 0 {
+		return err
+	}
+	errs := []error{}
+	for _, finding := range err.(*ValidationError).Errors {
+		if params.Severity[ValidatorOf(finding)] == SeverityWarning {
+			params.warnf(WarningValidation, "%v", finding)
+			continue
+		}
+		errs = append(errs, finding)
+	}
+	return validationError(errs)
+}
+
+// escalate fails with the first recorded warning of a code upgraded to an error by params.Severity
+func (params *Params) escalate() error {
+	for _, warning := range params.warnings {
+		if params.Severity[warning.Code.String()] == SeverityError {
+			return documentError(fmt.Errorf("%s warning is an error: %s", warning.Code, warning))
+		}
+	}
+	return nil
+}
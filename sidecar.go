@@ -0,0 +1,62 @@
+package iic
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// Sidecar represents content of the JSON file written next to the signed XML when Params.Sidecar is set.
+// Fingerprint is DocumentFingerprint of the signed XML
+type Sidecar struct {
+	IIC             string      `json:"IIC"`
+	IICSignature    string      `json:"IICSignature"`
+	PlainIIC        string      `json:"PlainIIC"`
+	VerificationURL string      `json:"VerificationURL"`
+	Fingerprint     string      `json:"Fingerprint"`
+	Vendor          *VendorInfo `json:"Vendor,omitempty"`
+}
+
+// VendorInfo identifies the software which produced the IIC, e.g. for support of multi-vendor service bureaus.
+// It's metadata of the results only and doesn't affect the IIC
+type VendorInfo struct {
+	Name    string `json:"Name"`
+	Version string `json:"Version"`
+}
+
+// vendorOf returns params.Vendor, or nil if it isn't set
+func vendorOf(params *Params) *VendorInfo {
+	if params.Vendor == (VendorInfo{}) {
+		return nil
+	}
+	vendor := params.Vendor
+	return &vendor
+}
+
+// SidecarPath returns path of the sidecar file for given output file: same basename with .iic.json extension
+func SidecarPath(outFile string) string {
+	return strings.TrimSuffix(outFile, filepath.Ext(outFile)) + ".iic.json"
+}
+
+// writeSidecar atomically writes sidecar of the invoice of signed doc next to params.OutFile, flushed to disk
+// unless params.NoSync is set
+func writeSidecar(doc *etree.Document, fields [7]string, iic string, iicSignature string, params *Params) error {
+	fingerprint, err := DocumentFingerprint(doc)
+	if err != nil {
+		return err
+	}
+	buf, err := json.MarshalIndent(Sidecar{
+		IIC:             iic,
+		IICSignature:    iicSignature,
+		PlainIIC:        PlainIIC(fields),
+		VerificationURL: VerificationURL(fields, iic),
+		Fingerprint:     fingerprint,
+		Vendor:          vendorOf(params),
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(SidecarPath(params.OutFile), buf, !params.NoSync)
+}
@@ -0,0 +1,109 @@
+package iic
+
+import (
+	"fmt"
+
+	"github.com/beevik/etree"
+)
+
+// SignedInvoice represents values of an invoice together with its IIC and IICSignature
+type SignedInvoice struct {
+	Fields       [7]string
+	IIC          string
+	IICSignature string
+}
+
+// VerificationURL returns address at which the invoice can be verified
+func (s SignedInvoice) VerificationURL() string {
+	return VerificationURL(s.Fields, s.IIC)
+}
+
+// SignedInvoices returns every Invoice of doc with its IIC and IICSignature. Values are read from attributes
+// or child elements, whichever the invoice has, see FieldAuto
+func SignedInvoices(doc *etree.Document) ([]SignedInvoice, error) {
+	invoices := doc.FindElements("//Invoice")
+	if len(invoices) == 0 {
+		return nil, documentError(fmt.Errorf("can't find element %s", "//Invoice"))
+	}
+
+	signed := make([]SignedInvoice, len(invoices))
+	for i, invoice := range invoices {
+		params, err := parseInvoice(doc, invoice, detectedParseOptions)
+		if err != nil {
+			return nil, documentError(fmt.Errorf("invoice %d: %v", i+1, err))
+		}
+		IIC, IICSignature, err := iicOf(invoice)
+		if err != nil {
+			return nil, documentError(fmt.Errorf("invoice %d: %v", i+1, err))
+		}
+		signed[i] = SignedInvoice{Fields: params, IIC: IIC, IICSignature: IICSignature}
+	}
+	return signed, nil
+}
+
+// ReadSignedInvoices is the same as SignedInvoices, but reads the document from file
+func ReadSignedInvoices(file string) ([]SignedInvoice, error) {
+	doc, _, err := readDocument(file)
+	if err != nil {
+		return nil, documentError(err)
+	}
+	return SignedInvoices(doc)
+}
+
+// ReadIIC returns IIC and IICSignature of the first Invoice of doc, the read counterpart of SetIIC.
+// Attributes are matched by local name, regardless of their namespace prefix
+func ReadIIC(doc *etree.Document) (string, string, error) {
+	return ReadIICAt(doc, 0)
+}
+
+// ReadIICAt is the same as ReadIIC, but reads Invoice at given index among Invoice elements of doc
+func ReadIICAt(doc *etree.Document, index int) (string, string, error) {
+	invoices := doc.FindElements("//Invoice")
+	if len(invoices) == 0 {
+		return "", "", documentError(fmt.Errorf("can't find element %s", "//Invoice"))
+	}
+	if index < 0 || index >= len(invoices) {
+		return "", "", documentError(fmt.Errorf("invoice index %d is out of range, document has %d invoices", index, len(invoices)))
+	}
+	IIC, IICSignature, err := iicOf(invoices[index])
+	if err != nil {
+		return "", "", documentError(fmt.Errorf("invoice %d: %v", index+1, err))
+	}
+	return IIC, IICSignature, nil
+}
+
+// iicOf returns IIC and IICSignature of the invoice, written either as its attributes or child elements,
+// see IICPlacement
+func iicOf(invoice *etree.Element) (string, string, error) {
+	if invoice.SelectElement("IIC") != nil {
+		IIC, err := textOf(invoice, "IIC")
+		if err != nil {
+			return "", "", err
+		}
+		IICSignature, err := textOf(invoice, "IICSignature")
+		if err != nil {
+			return "", "", err
+		}
+		return IIC, IICSignature, nil
+	}
+
+	IIC, err := localAttributeOf(invoice, "IIC")
+	if err != nil {
+		return "", "", err
+	}
+	IICSignature, err := localAttributeOf(invoice, "IICSignature")
+	if err != nil {
+		return "", "", err
+	}
+	return IIC, IICSignature, nil
+}
+
+// localAttributeOf returns value of an attribute with given local name, with or without namespace prefix
+func localAttributeOf(elem *etree.Element, key string) (string, error) {
+	for _, attr := range elem.Attr {
+		if attr.Key == key && attr.Space != "xmlns" {
+			return attr.Value, nil
+		}
+	}
+	return "", fmt.Errorf("can't find attribute %s", key)
+}
@@ -0,0 +1,139 @@
+package iic
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/noshto/dsig/pkg/safenet"
+)
+
+// Signer represents a private key able to create RSASSA-PKCS1-v1_5 signature of a sha256 hash.
+// *safenet.SafeNet satisfies this interface
+type Signer interface {
+	SignPKCS1v15(data []byte) ([]byte, error)
+}
+
+// CertificateSource represents a signer which is able to provide its X.509 certificate.
+// *safenet.SafeNet satisfies this interface
+type CertificateSource interface {
+	GetCertificate() (x509.Certificate, error)
+}
+
+// certificateOf returns certificate of given signer if it's a CertificateSource
+func certificateOf(signer Signer) (*x509.Certificate, error) {
+	source, ok := signer.(CertificateSource)
+	if !ok {
+		return nil, fmt.Errorf("signer %T doesn't provide a certificate", signer)
+	}
+	cert, err := source.GetCertificate()
+	if err != nil {
+		return nil, signerError(err)
+	}
+	return &cert, nil
+}
+
+// selectSigner returns signer registered for tin if params.Registry is set, otherwise given signer
+func selectSigner(signer Signer, params *Params, tin string) (Signer, error) {
+	if params.Registry == nil {
+		return signer, nil
+	}
+	return params.Registry.SignerForTIN(tin)
+}
+
+// withSigner calls f with params.Signer if it's set or params.Registry selects signers, otherwise
+// with SafeNet signer initialized from params.SafenetConfig and finalized after f returns.
+// In Sandbox, params.SandboxSigner is used if SafeNet can't be initialized
+func withSigner(params *Params, f func(Signer) error) error {
+	if params.Signer != nil || params.Registry != nil {
+		return f(params.Signer)
+	}
+
+	signer, err := initializeSafeNet(params.SafenetConfig, params.InitTimeout, params.PINFunc)
+	if err != nil {
+		fallback, err := sandboxFallback(params, err)
+		if err != nil {
+			return err
+		}
+		return f(fallback)
+	}
+	defer finalizeSafeNet(signer)
+
+	return f(signer)
+}
+
+// initializeSafeNet initializes SafeNet with given config. If it doesn't complete within timeout,
+// ErrSignerNotReady is returned and the late session is finalized in background. Zero timeout waits forever.
+// If pin is set, it's called for UnlockPin of a copy of config, which is cleared after initialization
+func initializeSafeNet(config *safenet.Config, timeout time.Duration, pin PINFunc) (*safenet.SafeNet, error) {
+	if pin != nil {
+		withPIN, err := configWithPIN(config, pin)
+		if err != nil {
+			return nil, err
+		}
+		config = withPIN
+	}
+	initialize := func(signer *safenet.SafeNet) error {
+		err := signer.Initialize(config)
+		if pin != nil {
+			ClearPIN(config)
+		}
+		if err == nil {
+			trackSession(signer)
+		}
+		return err
+	}
+
+	if timeout <= 0 {
+		signer := &safenet.SafeNet{}
+		if err := initialize(signer); err != nil {
+			return nil, initError(err)
+		}
+		return signer, nil
+	}
+
+	signer := &safenet.SafeNet{}
+	_, err := completeWithin(timeout, func() (interface{}, error) {
+		if err := initialize(signer); err != nil {
+			return nil, initError(err)
+		}
+		return signer, nil
+	}, func(interface{}) {
+		finalizeSafeNet(signer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// completeWithin runs open in background and returns its result if it completes within timeout, otherwise
+// fails with ErrSignerNotReady. The result of a late successful open is passed to abandon, so sessions it opened
+// are closed. The result is handed over through an unbuffered channel, so it's either received by the caller
+// still waiting for it or abandoned, never both or neither
+func completeWithin(timeout time.Duration, open func() (interface{}, error), abandon func(interface{})) (interface{}, error) {
+	type result struct {
+		value interface{}
+		err   error
+	}
+	done := make(chan result)
+	abandoned := make(chan struct{})
+	go func() {
+		value, err := open()
+		select {
+		case done <- result{value, err}:
+		case <-abandoned:
+			if err == nil {
+				abandon(value)
+			}
+		}
+	}()
+
+	select {
+	case result := <-done:
+		return result.value, result.err
+	case <-time.After(timeout):
+		close(abandoned)
+		return nil, signerError(fmt.Errorf("%w: initialization didn't complete within %s", ErrSignerNotReady, timeout))
+	}
+}
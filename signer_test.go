@@ -0,0 +1,55 @@
+package iic
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitOpenSessions waits until OpenSessions returns want, failing the test after a second
+func waitOpenSessions(t *testing.T, want int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); OpenSessions() != want; {
+		if time.Now().After(deadline) {
+			t.Fatalf("OpenSessions = %d, want %d", OpenSessions(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCompleteWithinLateInitialization(t *testing.T) {
+	before := OpenSessions()
+	released := make(chan struct{})
+	session := &sessionSigner{}
+	_, err := completeWithin(10*time.Millisecond, func() (interface{}, error) {
+		<-released
+		trackSession(session)
+		return session, nil
+	}, func(value interface{}) {
+		untrackSession(value)
+	})
+	if !errors.Is(err, ErrSignerNotReady) {
+		t.Fatalf("completeWithin returned %v, want ErrSignerNotReady", err)
+	}
+	close(released)
+	waitOpenSessions(t, before)
+}
+
+func TestCompleteWithinRace(t *testing.T) {
+	// initialization completing right at the timeout is either returned or abandoned, never leaked
+	before := OpenSessions()
+	for i := 0; i < 200; i++ {
+		session := &sessionSigner{}
+		value, err := completeWithin(time.Millisecond, func() (interface{}, error) {
+			time.Sleep(time.Millisecond)
+			trackSession(session)
+			return session, nil
+		}, func(value interface{}) {
+			untrackSession(value)
+		})
+		if err == nil {
+			untrackSession(value)
+		}
+	}
+	waitOpenSessions(t, before)
+}
@@ -0,0 +1,71 @@
+package iic
+
+import (
+	"fmt"
+
+	"github.com/beevik/etree"
+)
+
+// StagedIIC holds IICs computed for every Invoice of a document which aren't written into it yet,
+// along with ordinals reserved for them in Params.SeenStore
+type StagedIIC struct {
+	invoices  []*etree.Element
+	placement IICPlacement
+	store     SeenStore
+	reserved  []reservation
+	Results   []InvoiceResult
+}
+
+// Stage computes IIC for every Invoice of doc without modifying it. If any invoice fails, e.g. on HSM error
+// in the middle of the document, an error is returned, reserved ordinals are released and nothing should be
+// committed, which gives all-or-nothing semantics for a bundle. Otherwise ordinals stay reserved until
+// Commit or Discard. Params may be nil
+func Stage(signer Signer, doc *etree.Document, params *Params) (*StagedIIC, error) {
+	if params == nil {
+		params = &Params{}
+	}
+	staged, err := stage(signer, doc, params)
+	staged.reserved = params.takeReservations()
+	if err != nil {
+		settleReservations(staged.store, staged.reserved, false)
+		staged.reserved = nil
+	}
+	return staged, err
+}
+
+// stage is the same as Stage, but leaves reserved ordinals in params
+func stage(signer Signer, doc *etree.Document, params *Params) (*StagedIIC, error) {
+	invoices, results := computeInvoices(signer, doc, params)
+	staged := &StagedIIC{invoices: invoices, placement: params.IICPlacement, store: params.SeenStore, Results: results}
+	if len(invoices) == 0 {
+		return staged, documentError(fmt.Errorf("can't find element %s", "//Invoice"))
+	}
+	for _, result := range results {
+		if result.Status == InvoiceFailed {
+			return staged, fmt.Errorf("invoice %d: %w", result.Index+1, result.Err)
+		}
+	}
+	return staged, nil
+}
+
+// Commit writes staged IICs into the invoices of the document they were computed for and records their
+// reserved ordinals. Fails if the ordinals can't be recorded, IICs are written anyway
+func Commit(staged *StagedIIC) ([]InvoiceResult, error) {
+	results := staged.apply()
+	err := settleReservations(staged.store, staged.reserved, true)
+	staged.reserved = nil
+	return results, err
+}
+
+// Discard releases ordinals reserved for staged IICs which won't be committed
+func Discard(staged *StagedIIC) error {
+	err := settleReservations(staged.store, staged.reserved, false)
+	staged.reserved = nil
+	return err
+}
+
+// apply writes staged IICs into the invoices of the document they were computed for
+func (staged *StagedIIC) apply() []InvoiceResult {
+	setIICs(staged.invoices, staged.Results, staged.placement)
+	return staged.Results
+}
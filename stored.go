@@ -0,0 +1,95 @@
+package iic
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// StoredInvoice represents outcome of fiscalization of an invoice in a form suitable for persisting:
+// every field is a plain string or time, and the whole struct can be stored as a JSON column via
+// database/sql Valuer and Scanner. CertificateThumbprint is empty if the signer doesn't provide a certificate.
+// Vendor, when set, identifies the software which produced the IIC
+type StoredInvoice struct {
+	TIN                   string      `json:"TIN"`
+	IssueDateTime         string      `json:"IssueDateTime"`
+	InvOrdNum             string      `json:"InvOrdNum"`
+	BusinUnitCode         string      `json:"BusinUnitCode"`
+	TCRCode               string      `json:"TCRCode"`
+	SoftCode              string      `json:"SoftCode"`
+	TotPrice              string      `json:"TotPrice"`
+	IIC                   string      `json:"IIC"`
+	IICSignature          string      `json:"IICSignature"`
+	PlainIIC              string      `json:"PlainIIC"`
+	CertificateThumbprint string      `json:"CertificateThumbprint,omitempty"`
+	SignedAt              time.Time   `json:"SignedAt"`
+	Vendor                *VendorInfo `json:"Vendor,omitempty"`
+}
+
+// CertificateThumbprint returns hex encoded sha256 hash of DER encoded certificate
+func CertificateThumbprint(cert *x509.Certificate) string {
+	return fmt.Sprintf("%x", sha256.Sum256(cert.Raw))
+}
+
+// GenerateStoredInvoice generates IIC for fields using given signer and returns the result as StoredInvoice,
+// signed at time of Params.Clock, system time if it's nil, and attributed to Params.Vendor. Params may be nil
+func GenerateStoredInvoice(signer Signer, fields InvoiceFields, params *Params) (StoredInvoice, error) {
+	if params == nil {
+		params = &Params{}
+	}
+	IIC, IICSignature, err := generateIIC(signer, fields.Array())
+	if err != nil {
+		return StoredInvoice{}, err
+	}
+	stored := StoredInvoice{
+		TIN:           fields.TIN,
+		IssueDateTime: fields.IssueDateTime,
+		InvOrdNum:     fields.InvOrdNum,
+		BusinUnitCode: fields.BusinUnitCode,
+		TCRCode:       fields.TCRCode,
+		SoftCode:      fields.SoftCode,
+		TotPrice:      fields.TotPrice,
+		IIC:           IIC,
+		IICSignature:  IICSignature,
+		PlainIIC:      PlainIIC(fields.Array()),
+		SignedAt:      clockOrSystem(params.Clock).Now(),
+		Vendor:        vendorOf(params),
+	}
+	if cert, err := certificateOf(signer); err == nil {
+		stored.CertificateThumbprint = CertificateThumbprint(cert)
+	}
+	return stored, nil
+}
+
+// Fields returns values the IIC was generated from
+func (s StoredInvoice) Fields() InvoiceFields {
+	return InvoiceFields{
+		TIN:           s.TIN,
+		IssueDateTime: s.IssueDateTime,
+		InvOrdNum:     s.InvOrdNum,
+		BusinUnitCode: s.BusinUnitCode,
+		TCRCode:       s.TCRCode,
+		SoftCode:      s.SoftCode,
+		TotPrice:      s.TotPrice,
+	}
+}
+
+// Value implements driver.Valuer interface. Stores the invoice as JSON
+func (s StoredInvoice) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan implements sql.Scanner interface. Reads the invoice from JSON stored by Value
+func (s *StoredInvoice) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, s)
+	case string:
+		return json.Unmarshal([]byte(src), s)
+	default:
+		return fmt.Errorf("can't scan %T into StoredInvoice", src)
+	}
+}
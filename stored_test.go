@@ -0,0 +1,41 @@
+package iic
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestGenerateStoredInvoiceVendor(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	fields := FieldsOf(testInvoiceFields)
+	signedAt := time.Date(2019, 6, 12, 17, 6, 0, 0, time.UTC)
+	params := &Params{Clock: FixedClock(signedAt), Vendor: VendorInfo{Name: "Kasa", Version: "1.2.3"}}
+
+	stored, err := GenerateStoredInvoice(signer, fields, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.SignedAt.Equal(signedAt) {
+		t.Errorf("SignedAt = %v, want %v", stored.SignedAt, signedAt)
+	}
+	buf, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded StoredInvoice
+	if err := json.Unmarshal(buf, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Vendor == nil || *decoded.Vendor != params.Vendor {
+		t.Errorf("Vendor = %v, want %v", decoded.Vendor, params.Vendor)
+	}
+
+	stored, err = GenerateStoredInvoice(signer, fields, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Vendor != nil {
+		t.Errorf("Vendor = %v without Params.Vendor", stored.Vendor)
+	}
+}
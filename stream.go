@@ -0,0 +1,120 @@
+package iic
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/beevik/etree"
+)
+
+// StreamResult represents outcome of verification of a single invoice by VerifyStream. Index is position
+// of the invoice among Invoice elements of the document. A malformed document ends the stream with a result
+// of Index -1 carrying the error
+type StreamResult struct {
+	Index  int
+	Fields [7]string
+	IIC    string
+	Err    error
+}
+
+// VerifyStream verifies IIC and IICSignature of every Invoice read from r against the certificate with VerifyIIC,
+// sending results as soon as each invoice is read. The channel is closed when r is read or ctx is done, and must be
+// drained unless ctx is done; invoices aren't read after cancellation. Every Invoice is dropped once verified, so memory is bounded by the largest invoice plus elements other than
+// invoices, e.g. headers. Such elements, including Seller shared by invoices, must precede invoices using them
+func VerifyStream(ctx context.Context, cert *x509.Certificate, r io.Reader, opts ParseOptions) <-chan StreamResult {
+	results := make(chan StreamResult)
+	go func() {
+		defer close(results)
+		err := streamInvoices(r, func(doc *etree.Document, invoice *etree.Element, index int) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case results <- verifyStreamed(cert, doc, invoice, index, opts):
+				return nil
+			}
+		})
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+		case results <- StreamResult{Index: -1, Err: documentError(err)}:
+		}
+	}()
+	return results
+}
+
+// verifyStreamed verifies the invoice of doc, see VerifyStream
+func verifyStreamed(cert *x509.Certificate, doc *etree.Document, invoice *etree.Element, index int, opts ParseOptions) StreamResult {
+	result := StreamResult{Index: index}
+	fields, err := parseInvoice(doc, invoice, opts)
+	if err != nil {
+		result.Err = documentError(fmt.Errorf("invoice %d: %v", index+1, err))
+		return result
+	}
+	result.Fields = fields
+	IIC, IICSignature, err := iicOf(invoice)
+	if err != nil {
+		result.Err = documentError(fmt.Errorf("invoice %d: %v", index+1, err))
+		return result
+	}
+	result.IIC = IIC
+	result.Err = VerifyIIC(cert, fields, IIC, IICSignature)
+	return result
+}
+
+// streamInvoices reads XML document from r into a partial document, calling f with every Invoice once
+// its end tag is read and removing the Invoice from the document afterwards. Reading stops with the error of f
+func streamInvoices(r io.Reader, f func(doc *etree.Document, invoice *etree.Element, index int) error) error {
+	doc := etree.NewDocument()
+	decoder := xml.NewDecoder(r)
+	current := &doc.Element
+	index := 0
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF && current != &doc.Element {
+			return fmt.Errorf("unexpected end of document in element <%s>", current.FullTag())
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			current = current.CreateElement(qualifiedName(t.Name))
+			for _, attr := range t.Attr {
+				current.CreateAttr(qualifiedName(attr.Name), attr.Value)
+			}
+		case xml.EndElement:
+			element, parent := current, current.Parent()
+			if parent == nil {
+				return fmt.Errorf("unexpected end element </%s>", qualifiedName(t.Name))
+			}
+			current = parent
+			if element.Tag == "Invoice" {
+				if err := f(doc, element, index); err != nil {
+					return err
+				}
+				index++
+				parent.RemoveChild(element)
+			}
+		case xml.CharData:
+			if current != &doc.Element {
+				current.CreateCharData(string(t))
+			}
+		}
+	}
+}
+
+// qualifiedName returns name as written in the document, with its namespace prefix
+func qualifiedName(name xml.Name) string {
+	if len(name.Space) == 0 {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
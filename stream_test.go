@@ -0,0 +1,69 @@
+package iic
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+// signedTestBundle returns testBundle with given ordinals signed by signer
+func signedTestBundle(t *testing.T, signer Signer, ordinals ...string) []byte {
+	t.Helper()
+	doc := readTestDocument(t, testBundle(ordinals...))
+	staged, err := Stage(signer, doc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Commit(staged); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestVerifyStream(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	cert, err := certificateOf(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := signedTestBundle(t, signer, "1", "2", "3")
+	count := 0
+	for result := range VerifyStream(context.Background(), cert, bytes.NewReader(buf), ParseOptions{}) {
+		if result.Err != nil {
+			t.Errorf("invoice %d: %v", result.Index, result.Err)
+		}
+		count++
+	}
+	if count != 3 {
+		t.Errorf("%d invoices are verified, want 3", count)
+	}
+}
+
+func TestVerifyStreamCancel(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	cert, err := certificateOf(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := signedTestBundle(t, signer, "1", "2", "3", "4", "5")
+	ctx, cancel := context.WithCancel(context.Background())
+	results := VerifyStream(ctx, cert, bytes.NewReader(buf), ParseOptions{})
+	<-results
+	cancel()
+
+	// nothing receives while the stream is cancelled, so it must be closed without sending more results
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case result, ok := <-results:
+		if ok {
+			t.Errorf("invoice %d is sent after cancellation", result.Index)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream isn't closed after cancellation")
+	}
+}
@@ -0,0 +1,20 @@
+package iic
+
+// OutputStyle defines indentation of saved XML. Positive values indent with that many spaces.
+// Styles only affect serialization, IIC is computed before. Whatever quoting and spacing the input uses,
+// attributes are written in double quotes separated by single spaces, with &, <, >, " and ' in values
+// escaped as &amp;, &lt;, &gt;, &quot; and &apos;. IIC is computed from values with escapes resolved.
+// The XML declaration is kept as written, apart from encoding, see Params.OutputEncoding
+type OutputStyle int
+
+const (
+	// OutputTabs indents with tabs, it's the default
+	OutputTabs OutputStyle = 0
+	// OutputCompact removes all indentation, e.g. for transmission
+	OutputCompact OutputStyle = -1
+)
+
+// OutputSpaces returns style indenting with n spaces
+func OutputSpaces(n int) OutputStyle {
+	return OutputStyle(n)
+}
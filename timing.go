@@ -0,0 +1,43 @@
+package iic
+
+import "time"
+
+// Timings represents durations of phases of signing a document: reading, parsing and validation, signing,
+// and formatting and writing of the result
+type Timings struct {
+	ParseDur time.Duration
+	SignDur  time.Duration
+	WriteDur time.Duration
+}
+
+// add adds durations of other to t
+func (t *Timings) add(other Timings) {
+	t.ParseDur += other.ParseDur
+	t.SignDur += other.SignDur
+	t.WriteDur += other.WriteDur
+}
+
+// timingPhase selects duration of Timings
+type timingPhase int
+
+const (
+	parsePhase timingPhase = iota
+	signPhase
+	writePhase
+)
+
+// record adds time elapsed since started to given phase of params.timings, if timings are captured
+func (params *Params) record(phase timingPhase, started time.Time) {
+	if params.timings == nil {
+		return
+	}
+	elapsed := time.Since(started)
+	switch phase {
+	case parsePhase:
+		params.timings.ParseDur += elapsed
+	case signPhase:
+		params.timings.SignDur += elapsed
+	case writePhase:
+		params.timings.WriteDur += elapsed
+	}
+}
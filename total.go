@@ -0,0 +1,40 @@
+package iic
+
+import "github.com/beevik/etree"
+
+// TotalRecomputer keeps values of an invoice other than TotPrice, so IIC can be recomputed for amended
+// totals without parsing the document again, e.g. in bulk correction tools
+type TotalRecomputer struct {
+	signer Signer
+	fields [7]string
+}
+
+// NewTotalRecomputer parses the first Invoice of doc once for recomputing its IIC with amended totals
+func NewTotalRecomputer(signer Signer, doc *etree.Document, opts ParseOptions) (*TotalRecomputer, error) {
+	fields, err := parse(doc, opts)
+	if err != nil {
+		return nil, documentError(err)
+	}
+	return &TotalRecomputer{signer: signer, fields: fields}, nil
+}
+
+// IICForTotal validates total and generates IIC and IICSignature of the invoice with total as TotPrice.
+// The document isn't modified, use SetIIC to write the result
+func (r *TotalRecomputer) IICForTotal(total string) (string, string, error) {
+	if err := validateTotPrice(total); err != nil {
+		return "", "", documentError(err)
+	}
+	fields := r.fields
+	fields[6] = total
+	return generateIIC(r.signer, fields)
+}
+
+// RecomputeIICForTotal generates IIC and IICSignature of the first Invoice of doc with newTotPrice as TotPrice,
+// see TotalRecomputer for recomputing with several totals
+func RecomputeIICForTotal(signer Signer, doc *etree.Document, newTotPrice string) (string, string, error) {
+	r, err := NewTotalRecomputer(signer, doc, ParseOptions{})
+	if err != nil {
+		return "", "", err
+	}
+	return r.IICForTotal(newTotPrice)
+}
@@ -0,0 +1,128 @@
+package iic
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TrustStore holds certificates of taxpayers by TIN, e.g. published by the authority, for verification
+// without network access. A TIN may have several certificates, as keys are rotated
+type TrustStore struct {
+	mu    sync.RWMutex
+	certs map[string][]*x509.Certificate
+}
+
+// NewTrustStore creates empty TrustStore
+func NewTrustStore() *TrustStore {
+	return &TrustStore{certs: map[string][]*x509.Certificate{}}
+}
+
+// LoadTrustStore creates TrustStore from a PEM bundle, or from every .pem, .crt and .cer file of a directory
+func LoadTrustStore(path string) (*TrustStore, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		for _, pattern := range []string{"*.pem", "*.crt", "*.cer"} {
+			matches, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+	}
+
+	store := NewTrustStore()
+	for _, file := range files {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.AddPEM(buf); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	return store, nil
+}
+
+// AddPEM adds every certificate of PEM data, see Add
+func (s *TrustStore) AddPEM(data []byte) error {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return err
+		}
+		if err := s.Add(cert); err != nil {
+			return err
+		}
+	}
+}
+
+// Add adds cert for the TIN found in it, keeping certificates added for the TIN before
+func (s *TrustStore) Add(cert *x509.Certificate) error {
+	tin, err := TINFromCertificate(cert)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, known := range s.certs[tin] {
+		if known.Equal(cert) {
+			return nil
+		}
+	}
+	s.certs[tin] = append(s.certs[tin], cert)
+	return nil
+}
+
+// CertificatesForTIN returns certificates added for given TIN
+func (s *TrustStore) CertificatesForTIN(tin string) []*x509.Certificate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*x509.Certificate{}, s.certs[tin]...)
+}
+
+// VerifyIICOffline is the same as VerifyIIC, but uses certificates of store for TIN of params, succeeding if
+// any of them verifies. Returns ErrUnknownTIN if store has no certificate for the TIN
+func VerifyIICOffline(store *TrustStore, params [7]string, iic string, iicSignature string) error {
+	certs := store.CertificatesForTIN(params[0])
+	if len(certs) == 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownTIN, params[0])
+	}
+
+	var result error
+	preferred := false
+	for _, cert := range certs {
+		err := VerifyIIC(cert, params, iic, iicSignature)
+		if err == nil {
+			return nil
+		}
+		// Report failure of the certificate valid at IssueDateTime rather than of a rotated one
+		if valid := validAt(cert, params[1]); result == nil || valid && !preferred {
+			result, preferred = err, valid
+		}
+	}
+	return result
+}
+
+// validAt reports whether cert is valid at RFC 3339 time
+func validAt(cert *x509.Certificate, at string) bool {
+	t, err := parseDateTime(at)
+	return err == nil && !t.Before(cert.NotBefore) && !t.After(cert.NotAfter)
+}
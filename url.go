@@ -0,0 +1,69 @@
+package iic
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// VerificationBaseURL is the address of the authority's public invoice verifier
+const VerificationBaseURL = "https://mapr.tax.gov.me/ic/#/verify"
+
+// VerificationURL returns address at which the invoice can be verified, e.g. for the receipt QR code.
+// Orders of parameters are the same as for GenerateIIC
+func VerificationURL(params [7]string, iic string) string {
+	return VerificationBaseURL + "?" + VerificationParams(params, iic).Encode()
+}
+
+// VerificationParams returns query parameters of the verification URL: iic, tin, crtd, ord, bu, cr, sw and prc.
+// crtd is IssueDateTime exactly as it entered the IIC, since the verifier recomputes IIC from it
+func VerificationParams(params [7]string, iic string) url.Values {
+	values := url.Values{}
+	values.Set("iic", iic)
+	values.Set("tin", params[0])
+	values.Set("crtd", params[1])
+	values.Set("ord", params[2])
+	values.Set("bu", params[3])
+	values.Set("cr", params[4])
+	values.Set("sw", params[5])
+	values.Set("prc", params[6])
+	return values
+}
+
+// verificationKeys are names of query parameters of the verification URL holding values of the IIC,
+// in the order of GenerateIIC parameters
+var verificationKeys = [7]string{"tin", "crtd", "ord", "bu", "cr", "sw", "prc"}
+
+// IICResult represents IIC and IICSignature generated from values of the IIC
+type IICResult struct {
+	Fields       [7]string
+	IIC          string
+	IICSignature string
+}
+
+// GenerateIICFromValues generates IIC from values named as query parameters of the verification URL,
+// see VerificationParams, e.g. parsed from a scanned QR code. Every key but iic is required and its value
+// must be well-formed, see ValidateFields. cr may be empty, for invoices issued without a cash register
+func GenerateIICFromValues(signer Signer, v url.Values) (*IICResult, error) {
+	fields := [7]string{}
+	errs := []error{}
+	for i, key := range verificationKeys {
+		values, ok := v[key]
+		if !ok || len(values) == 0 {
+			errs = append(errs, fmt.Errorf("missing %s", key))
+			continue
+		}
+		fields[i] = values[0]
+	}
+	if len(errs) > 0 {
+		return nil, validationError(errs)
+	}
+	if err := validateFields(fields, SellerIDNum, ValidateOptions{TCRCodeOptional: true}); err != nil {
+		return nil, err
+	}
+
+	IIC, IICSignature, err := generateIIC(signer, fields)
+	if err != nil {
+		return nil, err
+	}
+	return &IICResult{Fields: fields, IIC: IIC, IICSignature: IICSignature}, nil
+}
@@ -0,0 +1,329 @@
+package iic
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/beevik/etree"
+)
+
+// BusinUnitCodePattern is the format of BusinUnitCode assigned on registration of a business unit:
+// two lowercase letters, three digits, two lowercase letters and three digits, e.g. bb123bb123
+const BusinUnitCodePattern = `^[a-z]{2}[0-9]{3}[a-z]{2}[0-9]{3}$`
+
+// TCRCodePattern is the format of TCRCode assigned on registration of a cash register, e.g. cc123cc123
+const TCRCodePattern = `^[a-z]{2}[0-9]{3}[a-z]{2}[0-9]{3}$`
+
+// SoftCodePattern is the format of SoftCode assigned on registration of the software, e.g. ss123ss123.
+// It changes when the software is re-registered, so keep it in sync with the registration
+const SoftCodePattern = `^[a-z]{2}[0-9]{3}[a-z]{2}[0-9]{3}$`
+
+// VATNumberPattern is the format of a VAT number identifying the seller, e.g. 30/31-12345-6
+const VATNumberPattern = `^[0-9]{2}/[0-9]{2}-[0-9]{5}-[0-9]$`
+
+// tinPattern is the format of IDNum of a seller of IDType TIN: 8 digits for legal entities, 13 for individuals
+const tinPattern = `^([0-9]{8}|[0-9]{13})$`
+
+var (
+	tinRegexp           = regexp.MustCompile(tinPattern)
+	vatNumberRegexp     = regexp.MustCompile(VATNumberPattern)
+	businUnitCodeRegexp = regexp.MustCompile(BusinUnitCodePattern)
+	tcrCodeRegexp       = regexp.MustCompile(TCRCodePattern)
+	softCodeRegexp      = regexp.MustCompile(SoftCodePattern)
+)
+
+// ValidateOptions adjusts checks of ValidateFields and ValidateDocument
+type ValidateOptions struct {
+	// TCRCodeOptional accepts empty TCRCode, as some invoice types are issued without a cash register
+	TCRCodeOptional bool
+}
+
+// ValidationError aggregates every problem found by a validator
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Is classifies ValidationError as ErrInvalidDocument
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidDocument
+}
+
+// ValidateBusinUnitCode checks that s matches BusinUnitCodePattern
+func ValidateBusinUnitCode(s string) error {
+	if !businUnitCodeRegexp.MatchString(s) {
+		return fmt.Errorf("BusinUnitCode %q doesn't match %s", s, BusinUnitCodePattern)
+	}
+	return nil
+}
+
+// ValidateTCRCode checks that s matches TCRCodePattern. Empty s is accepted only if optional is set
+func ValidateTCRCode(s string, optional bool) error {
+	if len(s) == 0 && optional {
+		return nil
+	}
+	if !tcrCodeRegexp.MatchString(s) {
+		return fmt.Errorf("TCRCode %q doesn't match %s", s, TCRCodePattern)
+	}
+	return nil
+}
+
+// ValidateSoftCode checks that s matches SoftCodePattern
+func ValidateSoftCode(s string) error {
+	if !softCodeRegexp.MatchString(s) {
+		return fmt.Errorf("SoftCode %q doesn't match %s", s, SoftCodePattern)
+	}
+	return nil
+}
+
+// ValidateSellerID checks that s is in the format of the seller identifier of given kind
+func ValidateSellerID(s string, id SellerID) error {
+	switch id {
+	case SellerVATNumber:
+		if !vatNumberRegexp.MatchString(s) {
+			return fmt.Errorf("VATNumber %q doesn't match %s", s, VATNumberPattern)
+		}
+	default:
+		if !tinRegexp.MatchString(s) {
+			return fmt.Errorf("IDNum %q is not a TIN of 8 or 13 digits", s)
+		}
+	}
+	return nil
+}
+
+// ValidateFields checks encoding, see ValidateEncoding, and format of every value of the IIC and returns all
+// problems at once as *ValidationError. Orders of parameters are the same as for GenerateIIC
+func ValidateFields(params [7]string, opts ValidateOptions) error {
+	errs := []error{}
+	for i, validate := range fieldValidators(opts) {
+		if err := validateEncoding(FieldNames[i], params[i]); err != nil {
+			errs = append(errs, &validatorError{id: ValidatorEncoding, err: err})
+			continue
+		}
+		if validate == nil {
+			continue
+		}
+		if err := validate(params[i]); err != nil {
+			errs = append(errs, &validatorError{id: fieldValidatorIDs[i], err: err})
+		}
+	}
+	return validationError(errs)
+}
+
+// ValidateEncoding checks that every value of the IIC is valid UTF-8 without control characters or
+// replacement characters. They appear in files corrupted by line ending conversion or decoding with a wrong
+// charset, and change the IIC while being invisible in an editor. Orders of parameters are the same as for GenerateIIC
+func ValidateEncoding(params [7]string) error {
+	errs := []error{}
+	for i, value := range params {
+		if err := validateEncoding(FieldNames[i], value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return validationError(errs)
+}
+
+// validateEncoding reports the first corrupted character of value with given name and its byte offset
+func validateEncoding(name string, value string) error {
+	for offset := 0; offset < len(value); {
+		r, size := utf8.DecodeRuneInString(value[offset:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			return fmt.Errorf("%s has invalid UTF-8 byte 0x%02x at offset %d", name, value[offset], offset)
+		case r == utf8.RuneError:
+			return fmt.Errorf("%s has replacement character %U of mis-decoded text at offset %d", name, r, offset)
+		case unicode.IsControl(r):
+			return fmt.Errorf("%s has control character %U at offset %d", name, r, offset)
+		}
+		offset += size
+	}
+	return nil
+}
+
+// validateField checks format of the IIC value with given name, values of other names aren't checked
+func validateField(name string, value string, opts ValidateOptions) error {
+	validators := fieldValidators(opts)
+	for i, fieldName := range FieldNames {
+		if fieldName == name && validators[i] != nil {
+			return validators[i](value)
+		}
+	}
+	return nil
+}
+
+// fieldValidators returns validators of the IIC values in the order of GenerateIIC parameters.
+// TIN isn't checked here, as its format depends on the kind of the seller identifier, see ValidateSellerID
+func fieldValidators(opts ValidateOptions) [7]func(string) error {
+	return [7]func(string) error{
+		nil,
+		validateIssueDateTime,
+		validateInvOrdNum,
+		ValidateBusinUnitCode,
+		func(s string) error {
+			return ValidateTCRCode(s, opts.TCRCodeOptional)
+		},
+		ValidateSoftCode,
+		validateTotPrice,
+	}
+}
+
+// validateIssueDateTime checks that s is in RFC 3339 format
+func validateIssueDateTime(s string) error {
+	if _, err := time.Parse(time.RFC3339, s); err != nil {
+		return fmt.Errorf("IssueDateTime %q is not in RFC 3339 format", s)
+	}
+	return nil
+}
+
+// validateInvOrdNum checks that s is a positive integer
+func validateInvOrdNum(s string) error {
+	if ordinal, err := strconv.Atoi(s); err != nil || ordinal < 1 {
+		return fmt.Errorf("InvOrdNum %q is not a positive integer", s)
+	}
+	return nil
+}
+
+// validateTotPrice checks that s is a plain decimal number, e.g. 99.01 or -12.5. NaN, infinities, exponents,
+// hexadecimal and digit separators, which strconv.ParseFloat accepts, aren't numbers for the IIC
+func validateTotPrice(s string) error {
+	if !bareNumberRegexp.MatchString(s) {
+		return fmt.Errorf("TotPrice %q is not a number", s)
+	}
+	return nil
+}
+
+// ValidateDocument checks that the first Invoice of doc has every value of the IIC in valid format,
+// and that fields required for its invoice type are present. Returns all problems at once as *ValidationError
+func ValidateDocument(doc *etree.Document, parseOpts ParseOptions, opts ValidateOptions) error {
+	invoice := doc.FindElement("//Invoice")
+	if invoice == nil {
+		return validationError([]error{fmt.Errorf("can't find element %s", "//Invoice")})
+	}
+	params, errs := lookupInvoice(doc, invoice, parseOpts)
+	if len(errs) > 0 {
+		return validationError(errs)
+	}
+	if err := validateFields(params, parseOpts.SellerID, opts); err != nil {
+		errs = append(errs, err.(*ValidationError).Errors...)
+	}
+	if err := ValidateCompanionFields(doc); err != nil {
+		errs = append(errs, err)
+	}
+	return validationError(errs)
+}
+
+// validateParsed checks values parsed from an invoice according to params, see validateFields.
+// Findings downgraded by p.Severity are recorded as warnings
+func validateParsed(params [7]string, p *Params) error {
+	return p.downgrade(validateFields(params, p.ParseOptions.SellerID, p.ValidateOptions))
+}
+
+// validateFields is the same as ValidateFields, but checks TIN as the seller identifier of given kind too
+func validateFields(params [7]string, id SellerID, opts ValidateOptions) error {
+	errs := []error{}
+	if validateEncoding(FieldNames[0], params[0]) == nil {
+		if err := ValidateSellerID(params[0], id); err != nil {
+			errs = append(errs, &validatorError{id: ValidatorTIN, err: err})
+		}
+	}
+	if err := ValidateFields(params, opts); err != nil {
+		errs = append(errs, err.(*ValidationError).Errors...)
+	}
+	return validationError(errs)
+}
+
+// validationError returns *ValidationError of errs, or nil if there are none
+func validationError(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: errs}
+}
+
+// checkExpectedDate warns when IssueDateTime isn't on the date of params.ExpectedDate, or fails if
+// params.ExpectedDateStrict is set. Dates are compared in timezones of IssueDateTime and ExpectedDate
+func checkExpectedDate(fields [7]string, params *Params) error {
+	if params.ExpectedDate.IsZero() {
+		return nil
+	}
+	issued, err := parseDateTime(fields[1])
+	if err != nil {
+		return documentError(fmt.Errorf("IssueDateTime %s is invalid: %v", fields[1], err))
+	}
+	issuedDate, expectedDate := issued.Format(issueDateLayout), params.ExpectedDate.Format(issueDateLayout)
+	if issuedDate == expectedDate {
+		return nil
+	}
+	if params.ExpectedDateStrict {
+		return documentError(fmt.Errorf("IssueDateTime %s isn't on the expected date %s", fields[1], expectedDate))
+	}
+	params.warnf(WarningDate, "IssueDateTime %s isn't on the expected date %s", fields[1], expectedDate)
+	return nil
+}
+
+// checkTotal fails if params.GuardTotal is set and TotPrice is empty or zero, e.g. 0.00, which is almost always
+// a total not computed yet. Zero is accepted if params.AllowZeroTotal is set
+func checkTotal(fields [7]string, params *Params) error {
+	if !params.GuardTotal {
+		return nil
+	}
+	if len(strings.TrimSpace(fields[6])) == 0 {
+		return documentError(fmt.Errorf("TotPrice %q is empty", fields[6]))
+	}
+	total, ok := new(big.Rat).SetString(fields[6])
+	if ok && total.Sign() == 0 && !params.AllowZeroTotal {
+		return documentError(fmt.Errorf("TotPrice %q is zero, allow zero-value invoices with AllowZeroTotal", fields[6]))
+	}
+	return nil
+}
+
+// checkSingleInvoice fails with ErrMultipleInvoices if doc has several Invoice elements, pointing to WriteIICAll,
+// or warns if params.SignFirstInvoice is set
+func checkSingleInvoice(doc *etree.Document, params *Params) error {
+	count := len(doc.FindElements("//Invoice"))
+	if count < 2 {
+		return nil
+	}
+	if params.SignFirstInvoice {
+		params.warnf(WarningInvoices, "document has %d invoices, only the first one is signed, use WriteIICAll to sign all", count)
+		return nil
+	}
+	return documentError(fmt.Errorf("%w: found %d, use WriteIICAll to sign all, or SignFirstInvoice to sign only the first one", ErrMultipleInvoices, count))
+}
+
+// warnSwapped warns when InvOrdNum and TCRCode look swapped, see swappedSuspicion
+func warnSwapped(fields [7]string, params *Params) {
+	if suspicion := swappedSuspicion(fields); len(suspicion) > 0 {
+		params.warnf(WarningSwapped, "%s", suspicion)
+	}
+}
+
+// swappedSuspicion explains why InvOrdNum and TCRCode look swapped: InvOrdNum looks like a TCR code, or TCRCode
+// is a number. Such values produce a valid-looking but wrong IIC, yet aren't rejected to avoid false positives.
+// Returns empty string if they don't look swapped
+func swappedSuspicion(fields [7]string) string {
+	ordinal, tcrCode := fields[2], fields[4]
+	_, errOrdinal := strconv.Atoi(ordinal)
+	_, errTCR := strconv.Atoi(tcrCode)
+	switch {
+	case errOrdinal != nil && tcrCodeRegexp.MatchString(ordinal):
+		return fmt.Sprintf("InvOrdNum %q looks like a TCR code, check whether InvOrdNum and TCRCode are swapped", ordinal)
+	case len(tcrCode) > 0 && errTCR == nil:
+		return fmt.Sprintf("TCRCode %q is a number, check whether InvOrdNum and TCRCode are swapped", tcrCode)
+	default:
+		return ""
+	}
+}
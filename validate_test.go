@@ -0,0 +1,63 @@
+package iic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckExpectedDateVariants(t *testing.T) {
+	params := &Params{ExpectedDate: time.Date(2019, 6, 12, 0, 0, 0, 0, time.UTC), ExpectedDateStrict: true}
+	for _, issued := range []string{"2019-06-12T17:05:43+02:00", "2019-06-12T17:05+02:00", "2019-06-12T17:05:43.250Z"} {
+		fields := testInvoiceFields
+		fields[1] = issued
+		if err := checkExpectedDate(fields, params); err != nil {
+			t.Errorf("%s: %v", issued, err)
+		}
+	}
+	fields := testInvoiceFields
+	fields[1] = "2019-06-13T00:05+02:00"
+	if err := checkExpectedDate(fields, params); err == nil {
+		t.Errorf("%s is accepted on %s", fields[1], params.ExpectedDate.Format(issueDateLayout))
+	}
+}
+
+func TestValidateTotPrice(t *testing.T) {
+	tests := []struct {
+		total string
+		valid bool
+	}{
+		{"99.01", true},
+		{"-12.5", true},
+		{"0", true},
+		{"1234567.89", true},
+		{"", false},
+		{"NaN", false},
+		{"Inf", false},
+		{"-Inf", false},
+		{"1e5", false},
+		{"0x1p3", false},
+		{"1_000", false},
+		{"+1.00", false},
+		{".5", false},
+		{"1.", false},
+		{"1,00", false},
+	}
+	for _, test := range tests {
+		if err := validateTotPrice(test.total); (err == nil) != test.valid {
+			t.Errorf("validateTotPrice(%q) returned %v, want valid %v", test.total, err, test.valid)
+		}
+	}
+}
+
+func TestIICForTotalRejectsNonDecimal(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	r, err := NewTotalRecomputer(signer, readTestDocument(t, testInvoice), ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, total := range []string{"NaN", "Inf", "1e5", "0x1p3", "1_000"} {
+		if _, _, err := r.IICForTotal(total); err == nil {
+			t.Errorf("IICForTotal(%q) succeeded", total)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package iic
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/beevik/etree"
+)
+
+// VATGroup represents a SameTax group of an invoice: total price of its items of a VAT rate before VAT,
+// and their VAT. VATAmt is empty for items exempt from VAT
+type VATGroup struct {
+	VATRate     string
+	PriceBefVAT string
+	VATAmt      string
+}
+
+// VATGroupsCheck compares Sum of totals of VAT groups, i.e. PriceBefVAT plus VATAmt, with TotPrice
+// the IIC is generated from. Sum is rounded to two decimals, see RoundPrice
+type VATGroupsCheck struct {
+	Groups   []VATGroup
+	Sum      string
+	TotPrice string
+}
+
+// Match reports whether Sum equals TotPrice rounded to two decimals
+func (c VATGroupsCheck) Match() bool {
+	total, ok := new(big.Rat).SetString(c.TotPrice)
+	return ok && roundRat(total) == c.Sum
+}
+
+// CheckVATGroups sums SameTax groups of the first Invoice of doc and compares the sum with its TotPrice,
+// for invoices with several VAT rates. Documents without SameTax groups are an error, so use it only for
+// documents carrying the breakdown, see RuleVATGroups for a business rule
+func CheckVATGroups(doc *etree.Document, opts ParseOptions) (VATGroupsCheck, error) {
+	fields, err := parse(doc, opts)
+	if err != nil {
+		return VATGroupsCheck{}, documentError(err)
+	}
+	check, err := vatGroupsOf(doc.FindElement("//Invoice"), fields[6])
+	if err != nil {
+		return VATGroupsCheck{}, documentError(err)
+	}
+	return check, nil
+}
+
+// RuleVATGroups requires TotPrice to equal sum of SameTax groups of the invoice. It isn't a part of
+// StandardRules, as not every document carries the breakdown
+var RuleVATGroups = Rule{
+	Name: "vat-groups",
+	Check: func(doc *etree.Document, invoice *etree.Element, fields [7]string) error {
+		check, err := vatGroupsOf(invoice, fields[6])
+		if err != nil {
+			return err
+		}
+		if !check.Match() {
+			return fmt.Errorf("sum of SameTax groups %s differs from TotPrice %s", check.Sum, check.TotPrice)
+		}
+		return nil
+	},
+}
+
+// vatGroupsOf sums SameTax groups of the invoice with given TotPrice
+func vatGroupsOf(invoice *etree.Element, totPrice string) (VATGroupsCheck, error) {
+	elements := invoice.FindElements(".//SameTax")
+	if len(elements) == 0 {
+		return VATGroupsCheck{}, fmt.Errorf("can't find element %s", "SameTax")
+	}
+
+	check := VATGroupsCheck{TotPrice: totPrice}
+	sum := new(big.Rat)
+	for i, element := range elements {
+		group := VATGroup{
+			VATRate:     element.SelectAttrValue("VATRate", ""),
+			PriceBefVAT: element.SelectAttrValue("PriceBefVAT", ""),
+			VATAmt:      element.SelectAttrValue("VATAmt", ""),
+		}
+		price, ok := new(big.Rat).SetString(group.PriceBefVAT)
+		if !ok {
+			return VATGroupsCheck{}, fmt.Errorf("SameTax %d: PriceBefVAT %q is not a number", i+1, group.PriceBefVAT)
+		}
+		sum.Add(sum, price)
+		if len(group.VATAmt) > 0 {
+			vat, ok := new(big.Rat).SetString(group.VATAmt)
+			if !ok {
+				return VATGroupsCheck{}, fmt.Errorf("SameTax %d: VATAmt %q is not a number", i+1, group.VATAmt)
+			}
+			sum.Add(sum, vat)
+		}
+		check.Groups = append(check.Groups, group)
+	}
+	check.Sum = roundRat(sum)
+	return check, nil
+}
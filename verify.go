@@ -0,0 +1,155 @@
+package iic
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// VerifyResult represents outcome of verification of a single file
+type VerifyResult struct {
+	File string
+	Err  error
+}
+
+// VerifyIIC checks that iicSignature is a signature of given params made with the certificate's key,
+// that iic is md5 hash of iicSignature and that the certificate was valid at IssueDateTime. Hex digits of
+// iic and iicSignature may be of either case
+func VerifyIIC(cert *x509.Certificate, params [7]string, iic string, iicSignature string) error {
+	signature, err := hex.DecodeString(iicSignature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+	if err := verifySignature(cert.PublicKey, DigestForIIC(params), signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+	if !strings.EqualFold(fmt.Sprintf("%x", md5.Sum(signature)), iic) {
+		return fmt.Errorf("%w: %s", ErrIICMismatch, iic)
+	}
+
+	issued, err := parseDateTime(params[1])
+	if err != nil {
+		return documentError(fmt.Errorf("IssueDateTime %s is invalid: %v", params[1], err))
+	}
+	if issued.Before(cert.NotBefore) || issued.After(cert.NotAfter) {
+		return fmt.Errorf("%w: issued at %s, certificate is valid from %s to %s", ErrCertificateNotValid, params[1], cert.NotBefore, cert.NotAfter)
+	}
+	return nil
+}
+
+// VerifyIICFile verifies IIC and IICSignature found in the file against given PEM encoded certificate.
+// Values are read from attributes or child elements, whichever the invoice has, see FieldAuto
+func VerifyIICFile(certPEM []byte, file string) error {
+	cert, err := parseCertificatePEM(certPEM)
+	if err != nil {
+		return err
+	}
+	return verifyIICFile(cert, file)
+}
+
+// VerifyBatch verifies files concurrently using given number of workers and returns result for every file
+func VerifyBatch(certPEM []byte, files []string, workers int) []VerifyResult {
+	results := make([]VerifyResult, len(files))
+	cert, err := parseCertificatePEM(certPEM)
+	if err != nil {
+		for i, file := range files {
+			results[i] = VerifyResult{File: file, Err: err}
+		}
+		return results
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = VerifyResult{File: files[i], Err: verifyIICFile(cert, files[i])}
+			}
+		}()
+	}
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// verifyIICFile verifies IIC and IICSignature found in the file against given certificate
+func verifyIICFile(cert *x509.Certificate, file string) error {
+	doc, _, err := readDocument(file)
+	if err != nil {
+		return documentError(err)
+	}
+	params, err := parse(doc, detectedParseOptions)
+	if err != nil {
+		return documentError(err)
+	}
+	IIC, IICSignature, err := ReadIIC(doc)
+	if err != nil {
+		return err
+	}
+	return VerifyIIC(cert, params, IIC, IICSignature)
+}
+
+// parseCertificatePEM decodes first certificate found in given PEM data
+func parseCertificatePEM(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("can't find certificate in PEM data")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// verifySignature checks that signature is a valid signature of sha256 digest: RSASSA-PKCS1-v1_5 for RSA
+// keys, ASN.1 encoded ECDSA for ECDSA keys, see Algorithm
+func verifySignature(pub crypto.PublicKey, digest []byte, signature []byte) error {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, signature)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, signature) {
+			return fmt.Errorf("ECDSA verification error")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// VerifyIICWithChain is the same as VerifyIIC, but also checks that leaf chains to one of roots, e.g. the
+// tax authority CA, at IssueDateTime. Intermediates may be nil. Trust failures are reported as
+// ErrUntrustedCertificate, distinctly from signature failures
+func VerifyIICWithChain(leaf *x509.Certificate, roots *x509.CertPool, intermediates *x509.CertPool, params [7]string, iic string, iicSignature string) error {
+	if err := VerifyIIC(leaf, params, iic, iicSignature); err != nil {
+		return err
+	}
+
+	issued, err := parseDateTime(params[1])
+	if err != nil {
+		return documentError(fmt.Errorf("IssueDateTime %s is invalid: %v", params[1], err))
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   issued,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUntrustedCertificate, err)
+	}
+	return nil
+}
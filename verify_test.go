@@ -0,0 +1,47 @@
+package iic
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestVerifyIICCase(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	IIC, IICSignature, err := generateIIC(signer, testInvoiceFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		IIC          string
+		IICSignature string
+	}{
+		{"lower", strings.ToLower(IIC), strings.ToLower(IICSignature)},
+		{"upper", strings.ToUpper(IIC), strings.ToUpper(IICSignature)},
+		{"mixed", strings.ToUpper(IIC), strings.ToLower(IICSignature)},
+	}
+	for _, test := range tests {
+		if err := VerifyIIC(signer.cert, testInvoiceFields, test.IIC, test.IICSignature); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+
+	other := strings.Repeat("0", len(IIC))
+	if err := VerifyIIC(signer.cert, testInvoiceFields, other, IICSignature); !errors.Is(err, ErrIICMismatch) {
+		t.Errorf("wrong IIC returned %v, want ErrIICMismatch", err)
+	}
+}
+
+func TestVerifyIICWithoutSeconds(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	fields := testInvoiceFields
+	fields[1] = "2019-06-12T17:05+02:00"
+	IIC, IICSignature, err := generateIIC(signer, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyIIC(signer.cert, fields, IIC, IICSignature); err != nil {
+		t.Error(err)
+	}
+}
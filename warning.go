@@ -0,0 +1,90 @@
+package iic
+
+import "fmt"
+
+// WarningCode identifies the kind of a Warning
+type WarningCode int
+
+const (
+	// WarningSwapped means that InvOrdNum and TCRCode look swapped, see swappedSuspicion
+	WarningSwapped WarningCode = iota
+	// WarningTotal means that the IIC uses a total other than TotPrice, while the invoice has TotPrice
+	WarningTotal
+	// WarningSoftCode means that SoftCode of the document was replaced with Params.SoftCode
+	WarningSoftCode
+	// WarningNormalized means that a value was rewritten by Params.CanonicalDateTime or Params.NormalizeTotal
+	WarningNormalized
+	// WarningSandbox means that Params.SandboxSigner was used, as SafeNet is unavailable
+	WarningSandbox
+	// WarningSessions means that fewer batch workers than requested are used
+	WarningSessions
+	// WarningDate means that IssueDateTime isn't on Params.ExpectedDate
+	WarningDate
+	// WarningValidation means that a finding of a validator was downgraded by Params.Severity
+	WarningValidation
+	// WarningInvoices means that only the first of several Invoice elements was signed, see Params.SignFirstInvoice
+	WarningInvoices
+)
+
+// String returns human readable name of the code
+func (c WarningCode) String() string {
+	switch c {
+	case WarningSwapped:
+		return "swapped"
+	case WarningTotal:
+		return "total"
+	case WarningSoftCode:
+		return "softcode"
+	case WarningNormalized:
+		return "normalized"
+	case WarningSandbox:
+		return "sandbox"
+	case WarningSessions:
+		return "sessions"
+	case WarningDate:
+		return "date"
+	case WarningValidation:
+		return "validation"
+	case WarningInvoices:
+		return "invoices"
+	default:
+		return "unknown"
+	}
+}
+
+// Warning represents a non-fatal finding: the operation succeeded, but a value may be wrong or was changed.
+// Invoice is the number of the Invoice the finding is about, counted from one, zero means the whole document
+type Warning struct {
+	Code    WarningCode
+	Invoice int
+	Message string
+}
+
+// String returns the message prefixed with number of the invoice if there is one
+func (w Warning) String() string {
+	if w.Invoice > 0 {
+		return fmt.Sprintf("invoice %d: %s", w.Invoice, w.Message)
+	}
+	return w.Message
+}
+
+// warningsOf returns warnings of the invoice with given index and those about the whole document
+func warningsOf(warnings []Warning, index int) []Warning {
+	var of []Warning
+	for _, warning := range warnings {
+		if warning.Invoice == 0 || warning.Invoice == index+1 {
+			of = append(of, warning)
+		}
+	}
+	return of
+}
+
+// aboutInvoice attributes warnings about the whole document to the invoice with given index
+func aboutInvoice(warnings []Warning, index int) []Warning {
+	for i := range warnings {
+		if warnings[i].Invoice == 0 {
+			warnings[i].Invoice = index + 1
+		}
+	}
+	return warnings
+}
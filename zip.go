@@ -0,0 +1,95 @@
+package iic
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// ZipManifestName is the name of the manifest entry written by WriteIICZip
+const ZipManifestName = "manifest.csv"
+
+// WriteIICZip reads every .xml entry of the input ZIP, generates IIC for it using given signer and writes
+// signed entries into the output ZIP under the same names. Non-XML entries are skipped. Entries are read like
+// InFile with default ReadSettings, byte order mark is dropped. Entries with several invoices fail with
+// ErrMultipleInvoices, as the manifest has an IIC per entry. The output always contains ZipManifestName listing
+// name, IIC, IICSignature and error of every XML entry; entries which failed are reported there and omitted
+// from the output
+func WriteIICZip(signer Signer, in io.Reader, out io.Writer) error {
+	buf, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	reader, err := zip.NewReader(bytes.NewReader(buf), int64(len(buf)))
+	if err != nil {
+		return documentError(err)
+	}
+
+	writer := zip.NewWriter(out)
+	manifest := [][]string{{"Name", "IIC", "IICSignature", "Error"}}
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() || !strings.EqualFold(path.Ext(file.Name), ".xml") {
+			continue
+		}
+		signed, IIC, IICSignature, err := signZipEntry(signer, file)
+		if err != nil {
+			manifest = append(manifest, []string{file.Name, "", "", err.Error()})
+			continue
+		}
+		entry, err := writer.Create(file.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(signed); err != nil {
+			return err
+		}
+		manifest = append(manifest, []string{file.Name, IIC, IICSignature, ""})
+	}
+
+	entry, err := writer.Create(ZipManifestName)
+	if err != nil {
+		return err
+	}
+	csvWriter := csv.NewWriter(entry)
+	if err := csvWriter.WriteAll(manifest); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// signZipEntry generates IIC for given ZIP entry and returns the signed XML
+func signZipEntry(signer Signer, file *zip.File) ([]byte, string, string, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, "", "", documentError(err)
+	}
+	defer rc.Close()
+	buf, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, "", "", documentError(err)
+	}
+
+	doc, _, err := parseDocument(buf, nil)
+	if err != nil {
+		return nil, "", "", documentError(err)
+	}
+	if count := len(doc.FindElements("//Invoice")); count > 1 {
+		return nil, "", "", documentError(fmt.Errorf("%w: found %d, a ZIP entry must have one invoice", ErrMultipleInvoices, count))
+	}
+	_, IIC, IICSignature, err := signDocument(signer, doc, &Params{Signer: signer})
+	if err != nil {
+		return nil, "", "", err
+	}
+	formatDocument(doc, OutputTabs)
+
+	signed, err := doc.WriteToBytes()
+	if err != nil {
+		return nil, "", "", err
+	}
+	return signed, IIC, IICSignature, nil
+}
@@ -0,0 +1,67 @@
+package iic
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestWriteIICZip(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	in := bytes.Buffer{}
+	writer := zip.NewWriter(&in)
+	for _, entry := range [][2]string{
+		{"bom.xml", string(utf8BOM) + testInvoice},
+		{"bundle.xml", testBundle("1", "2")},
+		{"readme.txt", "not an invoice"},
+	} {
+		w, err := writer.Create(entry[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(entry[1]))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := bytes.Buffer{}
+	if err := WriteIICZip(signer, &in, &out); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string][]byte{}
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[file.Name], _ = ioutil.ReadAll(rc)
+		rc.Close()
+	}
+	if len(entries) != 2 {
+		t.Fatalf("output has %d entries, want bom.xml and the manifest", len(entries))
+	}
+	if err := verifyTestDocument(t, signer, readTestDocument(t, string(entries["bom.xml"]))); err != nil {
+		t.Errorf("entry with byte order mark isn't signed: %v", err)
+	}
+
+	manifest, err := csv.NewReader(bytes.NewReader(entries[ZipManifestName])).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest) != 3 {
+		t.Fatalf("manifest has %d rows, want 3", len(manifest))
+	}
+	for _, row := range manifest[1:] {
+		if row[0] == "bundle.xml" && (row[1] != "" || !strings.Contains(row[3], ErrMultipleInvoices.Error())) {
+			t.Errorf("entry with several invoices is reported as %v, want ErrMultipleInvoices", row)
+		}
+	}
+}